@@ -6,9 +6,29 @@ IF NOT EXISTS `repositories`
     `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
     `name` VARCHAR(255) NOT NULL,
     `url` VARCHAR(255) NOT NULL,
+    `source_type` VARCHAR(16) NOT NULL DEFAULT 'git',
+    `team` VARCHAR(255) DEFAULT NULL,
+    `credential_type` VARCHAR(16) DEFAULT NULL,
+    `credential` TEXT DEFAULT NULL,
+    `clone_strategy` JSON DEFAULT NULL,
+    `max_retries` INT UNSIGNED DEFAULT NULL,
+    `schedule` JSON DEFAULT NULL,
+    `next_scan_time` datetime(6) DEFAULT NULL,
     `created_time` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     `updated_time` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    `status` tinyint DEFAULT 1
+    `status` tinyint DEFAULT 1,
+    INDEX (next_scan_time)
+) ENGINE = InnoDB DEFAULT CHARSET = UTF8MB4;
+
+
+CREATE TABLE
+IF NOT EXISTS `uploads`
+(
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `format` VARCHAR(16) NOT NULL,
+    `size` BIGINT UNSIGNED NOT NULL,
+    `created_time` datetime DEFAULT CURRENT_TIMESTAMP
 ) ENGINE = InnoDB DEFAULT CHARSET = UTF8MB4;
 
 
@@ -16,11 +36,80 @@ CREATE TABLE
 IF NOT EXISTS `scan_results`
 (
     `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
-    `repo_id` INT UNSIGNED NOT NULL,
+    `repo_id` INT UNSIGNED DEFAULT NULL,
+    `upload_id` INT UNSIGNED DEFAULT NULL,
+    `base_ref` VARCHAR(255) DEFAULT NULL,
+    `head_ref` VARCHAR(255) DEFAULT NULL,
     `result` JSON DEFAULT NULL,
     `queue_time` datetime DEFAULT NULL,
     `start_time` datetime DEFAULT NULL ,
     `end_time` datetime DEFAULT NULL,
     `status` tinyint DEFAULT 0,
+    `clone_strategy` JSON DEFAULT NULL,
+    `options` JSON DEFAULT NULL,
+    `cancelled_time` datetime DEFAULT NULL,
+    `cancelled_by` VARCHAR(255) DEFAULT NULL,
+    `progress` JSON DEFAULT NULL,
+    `commit_hash` VARCHAR(255) DEFAULT NULL,
+    `ruleset` VARCHAR(16) DEFAULT NULL,
+    `scan_key` CHAR(64) DEFAULT NULL,
+    `owner` VARCHAR(128) DEFAULT NULL,
+    INDEX (scan_key),
+    INDEX (status),
+    FOREIGN KEY (repo_id) REFERENCES repositories(id),
+    FOREIGN KEY (upload_id) REFERENCES uploads(id)
+) ENGINE = InnoDB DEFAULT CHARSET = UTF8MB4;
+
+
+CREATE TABLE
+IF NOT EXISTS `scan_jobs`
+(
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `result_id` INT UNSIGNED NOT NULL,
+    `repo_id` INT UNSIGNED NOT NULL,
+    `kind` VARCHAR(16) NOT NULL DEFAULT 'scan',
+    `attempts` INT UNSIGNED NOT NULL DEFAULT 0,
+    `retries` INT UNSIGNED NOT NULL DEFAULT 0,
+    `priority` tinyint NOT NULL DEFAULT 1,
+    `team` VARCHAR(255) NOT NULL DEFAULT '',
+    `host` VARCHAR(255) NOT NULL DEFAULT '',
+    `lease_owner` VARCHAR(128) DEFAULT NULL,
+    `lease_expires_time` datetime(6) DEFAULT NULL,
+    `created_time` datetime DEFAULT CURRENT_TIMESTAMP,
+    INDEX (lease_expires_time),
+    INDEX (host),
+    INDEX (team),
+    FOREIGN KEY (result_id) REFERENCES scan_results(id),
     FOREIGN KEY (repo_id) REFERENCES repositories(id)
+) ENGINE = InnoDB DEFAULT CHARSET = UTF8MB4;
+
+
+CREATE TABLE
+IF NOT EXISTS `scan_queue_lock`
+(
+    `id` TINYINT UNSIGNED NOT NULL PRIMARY KEY
+) ENGINE = InnoDB DEFAULT CHARSET = UTF8MB4;
+
+
+CREATE TABLE
+IF NOT EXISTS `scan_instances`
+(
+    `owner` VARCHAR(128) NOT NULL PRIMARY KEY,
+    `heartbeat_time` datetime(6) NOT NULL
+) ENGINE = InnoDB DEFAULT CHARSET = UTF8MB4;
+
+
+CREATE TABLE
+IF NOT EXISTS `scan_attempts`
+(
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `result_id` INT UNSIGNED NOT NULL,
+    `attempt` INT UNSIGNED NOT NULL,
+    `start_time` datetime DEFAULT NULL,
+    `end_time` datetime DEFAULT NULL,
+    `error` TEXT DEFAULT NULL,
+    `transient` BOOLEAN NOT NULL DEFAULT FALSE,
+    `retry_time` datetime DEFAULT NULL,
+    INDEX (result_id),
+    FOREIGN KEY (result_id) REFERENCES scan_results(id)
 ) ENGINE = InnoDB DEFAULT CHARSET = UTF8MB4;
\ No newline at end of file
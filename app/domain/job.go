@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// A ScanJob belong to the domain layer. It is the queued work of a scan result, which a worker
+// of any instance claims with a lease.
+type ScanJob struct {
+	ID       int64
+	ResultID int64
+	RepoID   int64
+	// scan or diff
+	Kind string
+	// Number of the claims of the job, a job is claimed again when its lease expires
+	Attempts int
+	// Number of the retries of the transient failures of the scan
+	Retries int
+	// Jobs of a higher priority of the team are claimed first, 0 is low, 1 normal and 2 high
+	Priority int
+	// Team of the repo, the teams with the fewest running jobs are claimed first
+	Team string
+	// Git host of the repo, empty for the local sources
+	Host             string
+	LeaseOwner       string
+	LeaseExpiresTime time.Time
+}
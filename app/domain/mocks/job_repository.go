@@ -0,0 +1,129 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/scanner/app/domain"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// JobRepository is a mock type for the JobRepository type
+type JobRepository struct {
+	mock.Mock
+}
+
+// Store provides a mock function with given fields: _a0
+func (_m *JobRepository) Store(_a0 *domain.ScanJob) (*domain.ScanJob, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *domain.ScanJob
+	if rf, ok := ret.Get(0).(func(*domain.ScanJob) *domain.ScanJob); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ScanJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*domain.ScanJob) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Claim provides a mock function with given fields: owner, lease
+func (_m *JobRepository) Claim(owner string, lease time.Duration) (*domain.ScanJob, error) {
+	ret := _m.Called(owner, lease)
+
+	var r0 *domain.ScanJob
+	if rf, ok := ret.Get(0).(func(string, time.Duration) *domain.ScanJob); ok {
+		r0 = rf(owner, lease)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ScanJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, time.Duration) error); ok {
+		r1 = rf(owner, lease)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Renew provides a mock function with given fields: job, lease
+func (_m *JobRepository) Renew(job *domain.ScanJob, lease time.Duration) error {
+	ret := _m.Called(job, lease)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*domain.ScanJob, time.Duration) error); ok {
+		r0 = rf(job, lease)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Complete provides a mock function with given fields: _a0
+func (_m *JobRepository) Complete(_a0 *domain.ScanJob) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*domain.ScanJob) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Retry provides a mock function with given fields: job, delay
+func (_m *JobRepository) Retry(job *domain.ScanJob, delay time.Duration) error {
+	ret := _m.Called(job, delay)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*domain.ScanJob, time.Duration) error); ok {
+		r0 = rf(job, delay)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Release provides a mock function with given fields: _a0
+func (_m *JobRepository) Release(_a0 *domain.ScanJob) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*domain.ScanJob) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Remove provides a mock function with given fields: resultID
+func (_m *JobRepository) Remove(resultID int64) error {
+	ret := _m.Called(resultID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(resultID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
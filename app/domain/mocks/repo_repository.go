@@ -116,3 +116,47 @@ func (_m *RepoRepository) Delete(_a0 *domain.Repo) (newRepo *domain.Repo, err er
 
 	return r0, r1
 }
+
+// FindDue provides a mock function with given fields:
+func (_m *RepoRepository) FindDue() (*domain.Repos, error) {
+	ret := _m.Called()
+
+	var r0 *domain.Repos
+	if rf, ok := ret.Get(0).(func() *domain.Repos); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Repos)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Reschedule provides a mock function with given fields: _a0
+func (_m *RepoRepository) Reschedule(_a0 *domain.Repo) (bool, error) {
+	ret := _m.Called(_a0)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(*domain.Repo) bool); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Bool(0)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*domain.Repo) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -1,6 +1,10 @@
 package mocks
 
 import (
+	"context"
+	"io"
+	"time"
+
 	domain "github.com/scanner/app/domain"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -10,12 +14,12 @@ type ScanRepository struct {
 	mock.Mock
 }
 
-func (_m *ScanRepository) Scan(repoUrl string) (*domain.ScanData, error) {
-	ret := _m.Called(repoUrl)
+func (_m *ScanRepository) Scan(ctx context.Context, repo *domain.Repo, options *domain.ScanOptions) (*domain.ScanData, error) {
+	ret := _m.Called(ctx, repo, options)
 
 	var r0 *domain.ScanData
-	if rf, ok := ret.Get(0).(func(string) *domain.ScanData); ok {
-		r0 = rf(repoUrl)
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Repo, *domain.ScanOptions) *domain.ScanData); ok {
+		r0 = rf(ctx, repo, options)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*domain.ScanData)
@@ -23,8 +27,100 @@ func (_m *ScanRepository) Scan(repoUrl string) (*domain.ScanData, error) {
 	}
 
 	var r2 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r2 = rf(repoUrl)
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.Repo, *domain.ScanOptions) error); ok {
+		r2 = rf(ctx, repo, options)
+	} else {
+		r2 = ret.Error(1)
+	}
+
+	return r0, r2
+}
+
+// ScanDiff provides a mock function with given fields: ctx, repo, base, head
+func (_m *ScanRepository) ScanDiff(ctx context.Context, repo *domain.Repo, base, head string) (*domain.ScanData, error) {
+	ret := _m.Called(ctx, repo, base, head)
+
+	var r0 *domain.ScanData
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Repo, string, string) *domain.ScanData); ok {
+		r0 = rf(ctx, repo, base, head)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ScanData)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.Repo, string, string) error); ok {
+		r2 = rf(ctx, repo, base, head)
+	} else {
+		r2 = ret.Error(1)
+	}
+
+	return r0, r2
+}
+
+// ScanArchive provides a mock function with given fields: ctx, upload, archive
+func (_m *ScanRepository) ScanArchive(ctx context.Context, upload *domain.Upload, archive io.ReaderAt) (*domain.ScanData, error) {
+	ret := _m.Called(ctx, upload, archive)
+
+	var r0 *domain.ScanData
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Upload, io.ReaderAt) *domain.ScanData); ok {
+		r0 = rf(ctx, upload, archive)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ScanData)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.Upload, io.ReaderAt) error); ok {
+		r2 = rf(ctx, upload, archive)
+	} else {
+		r2 = ret.Error(1)
+	}
+
+	return r0, r2
+}
+
+// ScanContent provides a mock function with given fields: filename, content
+func (_m *ScanRepository) ScanContent(filename string, content []byte) (*domain.ScanData, error) {
+	ret := _m.Called(filename, content)
+
+	var r0 *domain.ScanData
+	if rf, ok := ret.Get(0).(func(string, []byte) *domain.ScanData); ok {
+		r0 = rf(filename, content)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ScanData)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(1).(func(string, []byte) error); ok {
+		r2 = rf(filename, content)
+	} else {
+		r2 = ret.Error(1)
+	}
+
+	return r0, r2
+}
+
+// StoreUpload provides a mock function with given fields: _a0
+func (_m *ScanRepository) StoreUpload(_a0 *domain.Upload) (*domain.Upload, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *domain.Upload
+	if rf, ok := ret.Get(0).(func(*domain.Upload) *domain.Upload); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Upload)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(1).(func(*domain.Upload) error); ok {
+		r2 = rf(_a0)
 	} else {
 		r2 = ret.Error(1)
 	}
@@ -97,6 +193,27 @@ func (_m *ScanRepository) Store(_a0 *domain.ScanData) (newScanResult *domain.Sca
 	return r0, r1
 }
 
+// Start provides a mock function with given fields: _a0
+func (_m *ScanRepository) Start(_a0 *domain.ScanData) (updScanResult *domain.ScanResult, err error) {
+	ret := _m.Called(_a0)
+
+	var r0 *domain.ScanResult
+	if rf, ok := ret.Get(0).(func(*domain.ScanData) *domain.ScanResult); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(*domain.ScanResult)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*domain.ScanData) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Update provides a mock function with given fields: _a0
 func (_m *ScanRepository) Update(_a0 *domain.ScanData) (updScanResult *domain.ScanResult, err error) {
 	ret := _m.Called(_a0)
@@ -117,3 +234,246 @@ func (_m *ScanRepository) Update(_a0 *domain.ScanData) (updScanResult *domain.Sc
 
 	return r0, r1
 }
+
+// Cancel provides a mock function with given fields: _a0
+func (_m *ScanRepository) Cancel(_a0 *domain.ScanData) (updScanResult *domain.ScanResult, err error) {
+	ret := _m.Called(_a0)
+
+	var r0 *domain.ScanResult
+	if rf, ok := ret.Get(0).(func(*domain.ScanData) *domain.ScanResult); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ScanResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*domain.ScanData) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StoreAttempt provides a mock function with given fields: _a0
+func (_m *ScanRepository) StoreAttempt(_a0 *domain.AttemptData) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*domain.AttemptData) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StoreOnce provides a mock function with given fields: scanData, reuseFinished
+func (_m *ScanRepository) StoreOnce(scanData *domain.ScanData, reuseFinished bool) (*domain.ScanResult, bool, error) {
+	ret := _m.Called(scanData, reuseFinished)
+
+	var r0 *domain.ScanResult
+	if rf, ok := ret.Get(0).(func(*domain.ScanData, bool) *domain.ScanResult); ok {
+		r0 = rf(scanData, reuseFinished)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ScanResult)
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(*domain.ScanData, bool) bool); ok {
+		r1 = rf(scanData, reuseFinished)
+	} else {
+		r1 = ret.Bool(1)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(*domain.ScanData, bool) error); ok {
+		r2 = rf(scanData, reuseFinished)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// Resolve provides a mock function with given fields: ctx, repo, base, head
+func (_m *ScanRepository) Resolve(ctx context.Context, repo *domain.Repo, base string, head string) (string, error) {
+	ret := _m.Called(ctx, repo, base, head)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Repo, string, string) string); ok {
+		r0 = rf(ctx, repo, base, head)
+	} else {
+		r0 = ret.String(0)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.Repo, string, string) error); ok {
+		r1 = rf(ctx, repo, base, head)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Ruleset provides a mock function with given fields:
+func (_m *ScanRepository) Ruleset() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.String(0)
+	}
+
+	return r0
+}
+
+// Requeue provides a mock function with given fields: resultID
+func (_m *ScanRepository) Requeue(resultID int64) error {
+	ret := _m.Called(resultID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(resultID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StoreProgress provides a mock function with given fields: resultID, progress
+func (_m *ScanRepository) StoreProgress(resultID int64, progress *domain.ScanProgress) error {
+	ret := _m.Called(resultID, progress)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, *domain.ScanProgress) error); ok {
+		r0 = rf(resultID, progress)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CountActive provides a mock function with given fields: repoID
+func (_m *ScanRepository) CountActive(repoID int64) (int, error) {
+	ret := _m.Called(repoID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(int64) int); ok {
+		r0 = rf(repoID)
+	} else {
+		r0 = ret.Int(0)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(repoID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Heartbeat provides a mock function with given fields:
+func (_m *ScanRepository) Heartbeat() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Recover provides a mock function with given fields: ownerTimeout
+func (_m *ScanRepository) Recover(ownerTimeout time.Duration) ([]int64, []int64, error) {
+	ret := _m.Called(ownerTimeout)
+
+	var r0 []int64
+	if rf, ok := ret.Get(0).(func(time.Duration) []int64); ok {
+		r0 = rf(ownerTimeout)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int64)
+		}
+	}
+
+	var r1 []int64
+	if rf, ok := ret.Get(1).(func(time.Duration) []int64); ok {
+		r1 = rf(ownerTimeout)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]int64)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(time.Duration) error); ok {
+		r2 = rf(ownerTimeout)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SweepClones provides a mock function with given fields: ownerTimeout
+func (_m *ScanRepository) SweepClones(ownerTimeout time.Duration) ([]string, error) {
+	ret := _m.Called(ownerTimeout)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(time.Duration) []string); ok {
+		r0 = rf(ownerTimeout)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(time.Duration) error); ok {
+		r1 = rf(ownerTimeout)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindExpiringCertificates provides a mock function with given fields: repoID, window
+func (_m *ScanRepository) FindExpiringCertificates(repoID int64, window time.Duration) (*domain.CertificateReport, error) {
+	ret := _m.Called(repoID, window)
+
+	var r0 *domain.CertificateReport
+	if rf, ok := ret.Get(0).(func(int64, time.Duration) *domain.CertificateReport); ok {
+		r0 = rf(repoID, window)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.CertificateReport)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int64, time.Duration) error); ok {
+		r1 = rf(repoID, window)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
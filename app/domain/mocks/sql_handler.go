@@ -1,6 +1,9 @@
 package mocks
 
 import (
+	"database/sql"
+	"reflect"
+
 	"github.com/scanner/app/interfaces"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -10,9 +13,273 @@ type SQLHandler struct {
 	mock.Mock
 }
 
-func (s *SQLHandler) Query(string, ...interface{}) (row interfaces.Row, err error) {
-	return
+// Query provides a mock function with given fields: query, args
+func (_m *SQLHandler) Query(query string, args ...interface{}) (interfaces.Row, error) {
+	ret := _m.Called(query, args)
+
+	var r0 interfaces.Row
+	if rf, ok := ret.Get(0).(func(string, ...interface{}) interfaces.Row); ok {
+		r0 = rf(query, args...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interfaces.Row)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, ...interface{}) error); ok {
+		r1 = rf(query, args...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Exec provides a mock function with given fields: query, args
+func (_m *SQLHandler) Exec(query string, args ...interface{}) (interfaces.Result, error) {
+	ret := _m.Called(query, args)
+
+	var r0 interfaces.Result
+	if rf, ok := ret.Get(0).(func(string, ...interface{}) interfaces.Result); ok {
+		r0 = rf(query, args...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interfaces.Result)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, ...interface{}) error); ok {
+		r1 = rf(query, args...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Begin provides a mock function
+func (_m *SQLHandler) Begin() (interfaces.Tx, error) {
+	ret := _m.Called()
+
+	var r0 interfaces.Tx
+	if rf, ok := ret.Get(0).(func() interfaces.Tx); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interfaces.Tx)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Close provides a mock function
+func (_m *SQLHandler) Close() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Mocking Tx
+type Tx struct {
+	mock.Mock
+}
+
+// Query provides a mock function with given fields: query, args
+func (_m *Tx) Query(query string, args ...interface{}) (interfaces.Row, error) {
+	ret := _m.Called(query, args)
+
+	var r0 interfaces.Row
+	if rf, ok := ret.Get(0).(func(string, ...interface{}) interfaces.Row); ok {
+		r0 = rf(query, args...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interfaces.Row)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, ...interface{}) error); ok {
+		r1 = rf(query, args...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
-func (s *SQLHandler) Exec(string, ...interface{}) (result interfaces.Result, err error) {
-	return
+
+// Exec provides a mock function with given fields: query, args
+func (_m *Tx) Exec(query string, args ...interface{}) (interfaces.Result, error) {
+	ret := _m.Called(query, args)
+
+	var r0 interfaces.Result
+	if rf, ok := ret.Get(0).(func(string, ...interface{}) interfaces.Result); ok {
+		r0 = rf(query, args...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interfaces.Result)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, ...interface{}) error); ok {
+		r1 = rf(query, args...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Commit provides a mock function
+func (_m *Tx) Commit() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Rollback provides a mock function
+func (_m *Tx) Rollback() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Mocking Row, Next and Scan return the rows one by one
+type Row struct {
+	mock.Mock
+	Rows [][]interface{}
+
+	next int
+}
+
+// Scan copies the columns of the current row into dest, a nil column leaves its destination
+// at the zero value like a NULL of the database
+func (_m *Row) Scan(dest ...interface{}) error {
+	for i, value := range _m.Rows[_m.next-1] {
+		target := reflect.ValueOf(dest[i]).Elem()
+		target.Set(reflect.Zero(target.Type()))
+		if value == nil {
+			continue
+		}
+		if scanner, ok := dest[i].(sql.Scanner); ok {
+			if err := scanner.Scan(value); err != nil {
+				return err
+			}
+			continue
+		}
+		target.Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+// Next moves to the next row of Rows
+func (_m *Row) Next() bool {
+	_m.next++
+	return _m.next <= len(_m.Rows)
+}
+
+// Close provides a mock function
+func (_m *Row) Close() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Err provides a mock function
+func (_m *Row) Err() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Mocking Result
+type Result struct {
+	mock.Mock
+}
+
+// LastInsertId provides a mock function
+func (_m *Result) LastInsertId() (int64, error) {
+	ret := _m.Called()
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func() int64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RowsAffected provides a mock function
+func (_m *Result) RowsAffected() (int64, error) {
+	ret := _m.Called()
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func() int64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
@@ -7,10 +7,59 @@ type Repos []Repo
 
 // A Repo belong to the domain layer.
 type Repo struct {
-	ID          int64      `json:"id"`
-	Name        string     `json:"name"`
-	Url         string     `json:"url"`
-	CreatedTime *time.Time `json:"created_time,omitempty"`
-	UpdatedTime *time.Time `json:"updated_time,omitempty"`
-	Status      int8       `json:"status,omitempty"`
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Url  string `json:"url"`
+	// git clones the URL, local scans the directory or file:// repo on the scanner host
+	SourceType string `json:"source_type,omitempty"`
+	// Team which owns the repo, the queued scans of the teams are claimed in turn
+	Team           string         `json:"team,omitempty"`
+	CredentialType string         `json:"credential_type,omitempty"`
+	Credential     *Credential    `json:"-"`
+	CloneStrategy  *CloneStrategy `json:"clone_strategy,omitempty"`
+	// Retries of the transient scan failures, nil uses the default of the scanner
+	MaxRetries *int `json:"max_retries,omitempty"`
+	// Recurring scans of the repo, the next one is queued at the next scan time
+	Schedule     *Schedule  `json:"schedule,omitempty"`
+	NextScanTime *time.Time `json:"next_scan_time,omitempty"`
+	CreatedTime  *time.Time `json:"created_time,omitempty"`
+	UpdatedTime  *time.Time `json:"updated_time,omitempty"`
+	Status       int8       `json:"status,omitempty"`
+}
+
+// A Credential belong to the domain layer. It is stored encrypted and never returned by the API.
+type Credential struct {
+	// https, ssh or none to remove the credential
+	Type       string `json:"type"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+	// strict or insecure, strict verifies the host key against KnownHosts or the system known_hosts
+	KnownHostsPolicy string `json:"known_hosts_policy,omitempty"`
+	KnownHosts       string `json:"known_hosts,omitempty"`
+}
+
+// A CloneStrategy belong to the domain layer.
+type CloneStrategy struct {
+	// 0 clones the full history
+	Depth        int    `json:"depth,omitempty"`
+	Branch       string `json:"branch,omitempty"`
+	SingleBranch bool   `json:"single_branch"`
+	Tags         bool   `json:"tags"`
+	// Only these directories are checked out when given
+	SparsePaths []string `json:"sparse_paths,omitempty"`
+	// none, top-level, recursive or allowlist
+	SubmoduleMode string `json:"submodule_mode"`
+	// Hosts of the submodules which are cloned in allowlist mode
+	SubmoduleHosts []string `json:"submodule_hosts,omitempty"`
+}
+
+// A Schedule belong to the domain layer.
+type Schedule struct {
+	// Cron expression of the minute, hour, day of month, month and day of week, or a macro
+	// such as @daily
+	Cron string `json:"cron"`
+	// IANA time zone of the expression, UTC when it is not given
+	Timezone string `json:"timezone,omitempty"`
 }
@@ -7,13 +7,29 @@ type ScanResults []ScanResult
 
 // A ScanData belong to the domain layer.
 type ScanData struct {
-	ID        int64
-	RepoID    int64
+	ID     int64
+	RepoID int64
+	// Uploaded archive which was scanned instead of a repo
+	UploadID int64
+	// Revisions of a diff scan, only the lines which head adds to base are scanned
+	BaseRef   string
+	HeadRef   string
 	Status    int8
 	QueueTime time.Time
 	StartTime time.Time
 	EndTime   time.Time
 	Result    string
+	// Clone strategy which was used for the scan
+	CloneStrategy *CloneStrategy
+	Options       *ScanOptions
+	// User who cancelled the scan
+	CancelledTime time.Time
+	CancelledBy   string
+	// Resolved commit of the scan, "base..head" for a diff scan, and the version of the rules
+	Commit  string
+	Ruleset string
+	// Scans of the same key find the same violations, they are run once
+	ScanKey string
 }
 
 // A ScanData belong to the domain layer.
@@ -26,4 +42,86 @@ type ScanResult struct {
 	EndTime   string `json:"end_time,omitempty"`
 	Status    string `json:"status,omitempty"`
 	Result    string `json:"result,omitempty"`
+	// Clone strategy which was used for the scan
+	CloneStrategy *CloneStrategy `json:"clone_strategy,omitempty"`
+	// Uploaded archive which was scanned instead of a repo
+	UploadID int64 `json:"upload_id,omitempty"`
+	// Revisions of a diff scan
+	BaseRef string       `json:"base_ref,omitempty"`
+	HeadRef string       `json:"head_ref,omitempty"`
+	Options *ScanOptions `json:"options,omitempty"`
+	// User who cancelled the scan
+	CancelledTime string `json:"cancelled_time,omitempty"`
+	CancelledBy   string `json:"cancelled_by,omitempty"`
+	// Runs of the queued scan
+	Attempts []ScanAttempt `json:"attempts,omitempty"`
+	// Last progress of the running scan
+	Progress *ScanProgress `json:"progress,omitempty"`
+	// Resolved commit of the scan and the version of the rules
+	Commit  string `json:"commit,omitempty"`
+	Ruleset string `json:"ruleset,omitempty"`
+}
+
+// A ScanProgress belong to the domain layer. It is the live state of a queued scan.
+type ScanProgress struct {
+	// queued, cloning, walking, scanning, storing or done
+	Phase           string `json:"phase"`
+	FilesDiscovered int64  `json:"files_discovered"`
+	FilesScanned    int64  `json:"files_scanned"`
+	Findings        int64  `json:"findings"`
+	// Final status of the scan when the phase is done
+	Status string `json:"status,omitempty"`
+}
+
+// A AttemptData belong to the domain layer. It is one run of a queued scan.
+type AttemptData struct {
+	ResultID  int64
+	Attempt   int
+	StartTime time.Time
+	EndTime   time.Time
+	Error     string
+	// Transient failures are retried until the maximum retries of the repo
+	Transient bool
+	RetryTime time.Time
+}
+
+// A ScanAttempt belong to the domain layer.
+type ScanAttempt struct {
+	Attempt   int    `json:"attempt"`
+	StartTime string `json:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Transient bool   `json:"transient,omitempty"`
+	// Time after which the failed attempt is retried
+	RetryTime string `json:"retry_time,omitempty"`
+}
+
+// A ScanOptions belong to the domain layer.
+type ScanOptions struct {
+	// Attribute the findings to the commits which last changed their lines
+	Blame bool `json:"blame,omitempty"`
+	// Work out when the matched values were introduced and removed in the history
+	Timeline bool `json:"timeline,omitempty"`
+}
+
+// A CertificateReport belong to the domain layer.
+type CertificateReport struct {
+	RepoID       int64         `json:"repo_id"`
+	ResultID     int64         `json:"result_id"`
+	WindowDays   int           `json:"window_days"`
+	Certificates []Certificate `json:"certificates"`
+}
+
+// A Certificate belong to the domain layer.
+type Certificate struct {
+	Path       string   `json:"path"`
+	Line       string   `json:"line"`
+	Subject    string   `json:"subject"`
+	Issuer     string   `json:"issuer"`
+	SANs       []string `json:"sans,omitempty"`
+	Serial     string   `json:"serial"`
+	NotAfter   string   `json:"not_after"`
+	SelfSigned bool     `json:"self_signed"`
+	Expired    bool     `json:"expired"`
+	Severity   string   `json:"severity"`
 }
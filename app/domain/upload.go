@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// An Upload belong to the domain layer. It is the ad-hoc source of an uploaded archive scan.
+type Upload struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// tar.gz, zip or content of the ad-hoc content scans
+	Format      string     `json:"format"`
+	Size        int64      `json:"size"`
+	CreatedTime *time.Time `json:"created_time,omitempty"`
+}
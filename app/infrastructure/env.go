@@ -28,7 +28,11 @@ func Load(logger *zap.Logger) {
 	}
 
 	for _, l := range lines {
-		pair := strings.Split(l, "=")
+		// Values can contain "=", e.g. regular expressions and base64 keys
+		pair := strings.SplitN(l, "=", 2)
+		if len(pair) != 2 {
+			continue
+		}
 		os.Setenv(pair[0], pair[1])
 	}
 }
@@ -1,9 +1,14 @@
 package infrastructure
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -12,35 +17,101 @@ import (
 	"go.uber.org/zap"
 )
 
-// Dispatch is handle routing
+// Dispatch is handle routing. It serves the requests until SIGINT or SIGTERM, then the instance
+// is not ready anymore, keeps serving for the pre-stop delay so the load balancers see it, and
+// drains the requests and the running scans before it closes the database connection.
 func Dispatch(logger *zap.Logger, sqlHandler interfaces.SQLHandler) {
+	healthController := interfaces.NewHealthController()
 	repoController := interfaces.NewRepoController(sqlHandler, logger)
 	scanController := interfaces.NewScanController(sqlHandler, logger)
+	scanController.Draining = healthController.Draining()
 	r := chi.NewRouter()
-	// Set a timeout value on the request context (ctx), that will signal
-	// through ctx.Done() that the request has timed out and further
-	// processing should be stopped.
-	r.Use(middleware.Timeout(60 * time.Second))
-	r.Route("/api", func(r chi.Router) {
-		r.Get("/repos", repoController.Index)
-		r.Route("/repo", func(r chi.Router) {
-			r.Post("/", repoController.Create)
-			r.Get("/{repoID}", repoController.Show)
-			r.Put("/{repoID}", repoController.Update)
-			r.Delete("/{repoID}", repoController.Delete)
-			r.Post("/{repoID}/scan", scanController.Scan)
-		})
+	// Event streams last until the scan is done, without the timeout of the other requests
+	r.Get("/api/scan/result/{resultID}/events", scanController.Events)
+	r.Group(func(r chi.Router) {
+		// Set a timeout value on the request context (ctx), that will signal
+		// through ctx.Done() that the request has timed out and further
+		// processing should be stopped.
+		r.Use(middleware.Timeout(60 * time.Second))
+		r.Route("/api", func(r chi.Router) {
+			r.Get("/ready", healthController.Ready)
+			r.Get("/repos", repoController.Index)
+			r.Route("/repo", func(r chi.Router) {
+				r.Post("/", repoController.Create)
+				r.Get("/{repoID}", repoController.Show)
+				r.Put("/{repoID}", repoController.Update)
+				r.Delete("/{repoID}", repoController.Delete)
+				r.Post("/{repoID}/scan", scanController.Scan)
+				r.Post("/{repoID}/scan/diff", scanController.ScanDiff)
+				r.Get("/{repoID}/certificates", scanController.Certificates)
+			})
 
-		r.Route("/scan", func(r chi.Router) {
-			r.Get("/results", scanController.Index)
-			r.Get("/result/{resultID}", scanController.Show)
+			r.Route("/scan", func(r chi.Router) {
+				r.Get("/results", scanController.Index)
+				r.Get("/result/{resultID}", scanController.Show)
+				r.Post("/result/{resultID}/cancel", scanController.Cancel)
+				r.Post("/upload", scanController.Upload)
+				r.Post("/content", scanController.Content)
+			})
 		})
 	})
 
+	// Requests and scans are drained for 30 seconds by default
+	drainTimeout, err := strconv.Atoi(os.Getenv("SHUTDOWN_DRAIN_TIMEOUT"))
+	if err != nil || drainTimeout < 0 {
+		drainTimeout = 30
+	}
+	// Requests are still taken for 5 seconds by default, within the drain timeout
+	preStopDelay, err := strconv.Atoi(os.Getenv("SHUTDOWN_PRESTOP_DELAY"))
+	if err != nil || preStopDelay < 0 {
+		preStopDelay = 5
+	}
+
 	logger.Info(fmt.Sprintf("Starting server at port %s\n", os.Getenv("SERVER_PORT")))
 
-	if err := http.ListenAndServe(":"+os.Getenv("SERVER_PORT"), r); err != nil {
+	server := &http.Server{Addr: ":" + os.Getenv("SERVER_PORT"), Handler: r}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ListenAndServe()
+	}()
+	serving := true
+	select {
+	case err := <-served:
 		logger.Error(fmt.Sprintf("%s", err))
+		serving = false
+	case received := <-signals:
+		logger.Info(fmt.Sprintf("Draining the server after %s\n", received))
 	}
+	signal.Stop(signals)
 
+	healthController.Drain()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(drainTimeout)*time.Second)
+	defer cancel()
+	if serving {
+		// Load balancers stop sending requests once /api/ready responds 503 to their checks
+		select {
+		case <-time.After(time.Duration(preStopDelay) * time.Second):
+		case <-ctx.Done():
+		}
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// Requests which are still running after the timeout are dropped
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error(fmt.Sprintf("unable to drain the requests: %s", err))
+			server.Close()
+		}
+	}()
+	deadline, _ := ctx.Deadline()
+	scanController.Close(time.Until(deadline))
+	wg.Wait()
+
+	if err := sqlHandler.Close(); err != nil {
+		logger.Error(fmt.Sprintf("unable to close the database connection: %s", err))
+	}
+	logger.Info("Server stopped")
 }
@@ -13,6 +13,11 @@ type SQLHandler struct {
 	Conn *sql.DB
 }
 
+// A Tx belong to the infrastructure layer.
+type Tx struct {
+	Tx *sql.Tx
+}
+
 // A Result belong to the infrastructure layer.
 type Result struct {
 	Result sql.Result
@@ -65,6 +70,46 @@ func (s *SQLHandler) Exec(query string, args ...interface{}) (interfaces.Result,
 	return result, nil
 }
 
+// Close closes the connections of the database.
+func (s *SQLHandler) Close() error {
+	return s.Conn.Close()
+}
+
+// Begin starts a transaction.
+func (s *SQLHandler) Begin() (interfaces.Tx, error) {
+	tx, err := s.Conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx}, nil
+}
+
+// Query returns results of a Query method in the transaction.
+func (t *Tx) Query(query string, args ...interface{}) (interfaces.Row, error) {
+	rows, err := t.Tx.Query(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Row{Rows: rows}, nil
+}
+
+// Exec is execute statement in the transaction.
+func (t *Tx) Exec(query string, args ...interface{}) (interfaces.Result, error) {
+	return t.Tx.Exec(query, args...)
+}
+
+// Commit commits the transaction.
+func (t *Tx) Commit() error {
+	return t.Tx.Commit()
+}
+
+// Rollback aborts the transaction.
+func (t *Tx) Rollback() error {
+	return t.Tx.Rollback()
+}
+
 // LastInsertId returns results of a LastInsertId method.
 func (r Result) LastInsertId() (int64, error) {
 	return r.Result.LastInsertId()
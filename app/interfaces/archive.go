@@ -0,0 +1,137 @@
+package interfaces
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var errInvalidArchive = errors.New("invalid archive")
+
+// Detect the format of the archive from its file name, empty if it is not supported
+func archiveFormat(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	}
+	return ""
+}
+
+// An extractor writes the entries of an archive into the directory within the limits.
+// The limits are checked on the extracted data, the sizes in the archive headers are not trusted.
+type extractor struct {
+	directory string
+	// Maximum total size in bytes of the extracted files, 0 disables the limit
+	maxSize int64
+	// Maximum number of the extracted files, 0 disables the limit
+	maxFiles int
+	size     int64
+	files    int
+}
+
+// Extract the gzip compressed tarball
+func (e *extractor) extractTarGz(reader io.Reader) error {
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidArchive, err)
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidArchive, err)
+		}
+		if err = e.extract(header.Name, header.FileInfo().Mode(), tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+// Extract the zip archive
+func (e *extractor) extractZip(reader io.ReaderAt, size int64) error {
+	zipReader, err := zip.NewReader(reader, size)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidArchive, err)
+	}
+	for _, file := range zipReader.File {
+		entry, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidArchive, err)
+		}
+		err = e.extract(file.Name, file.Mode(), entry)
+		entry.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write the entry into the directory. Only the directories and the regular files are
+// extracted, the links and the special files are skipped.
+func (e *extractor) extract(name string, mode os.FileMode, reader io.Reader) error {
+	target, err := e.target(name)
+	if err != nil {
+		return err
+	}
+	if mode.IsDir() {
+		return os.MkdirAll(target, 0700)
+	}
+	if !mode.IsRegular() {
+		return nil
+	}
+	e.files++
+	if e.maxFiles > 0 && e.files > e.maxFiles {
+		return fmt.Errorf("%w: more than %d files", errInvalidArchive, e.maxFiles)
+	}
+	if err = os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if e.maxSize > 0 {
+		// One byte more than the remaining size is read to detect the archive bombs
+		reader = io.LimitReader(reader, e.maxSize-e.size+1)
+	}
+	n, err := io.Copy(out, reader)
+	e.size += n
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidArchive, err)
+	}
+	if e.maxSize > 0 && e.size > e.maxSize {
+		return fmt.Errorf("%w: extracted size exceeds %d bytes", errInvalidArchive, e.maxSize)
+	}
+	return nil
+}
+
+// Resolve the path of the entry inside the directory. The absolute paths and the paths
+// which leave the directory are rejected.
+func (e *extractor) target(name string) (string, error) {
+	// Zip archives of Windows can use backslashes
+	name = strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(name) || filepath.VolumeName(name) != "" || (len(name) > 1 && name[1] == ':') {
+		return "", fmt.Errorf("%w: absolute path %s", errInvalidArchive, name)
+	}
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: path %s is outside of the archive", errInvalidArchive, name)
+	}
+	return filepath.Join(e.directory, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,115 @@
+package interfaces
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Build a tar.gz archive of the entries
+func testTarGz(t *testing.T, entries []*tar.Header, contents []string) []byte {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for i, header := range entries {
+		header.Size = int64(len(contents[i]))
+		assert.NoError(t, tarWriter.WriteHeader(header))
+		_, err := tarWriter.Write([]byte(contents[i]))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tarWriter.Close())
+	assert.NoError(t, gzipWriter.Close())
+	return buf.Bytes()
+}
+
+// Build a zip archive of the files
+func testZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for name, content := range files {
+		writer, err := zipWriter.Create(name)
+		assert.NoError(t, err)
+		_, err = writer.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zipWriter.Close())
+	return buf.Bytes()
+}
+
+// Test the safe extraction of the archives
+func TestExtractArchive(t *testing.T) {
+	t.Run("tar.gz", func(t *testing.T) {
+		directory := t.TempDir()
+		archive := testTarGz(t, []*tar.Header{
+			{Name: "src/main.go", Typeflag: tar.TypeReg, Mode: 0644},
+			{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		}, []string{"package main", ""})
+		e := &extractor{directory: directory}
+		assert.NoError(t, e.extractTarGz(bytes.NewReader(archive)))
+		assert.FileExists(t, filepath.Join(directory, "src", "main.go"))
+		assert.NoFileExists(t, filepath.Join(directory, "link"))
+	})
+
+	t.Run("traversal", func(t *testing.T) {
+		for _, name := range []string{"../evil.go", "src/../../evil.go", "/etc/evil.go", "..\\evil.go", "C:\\evil.go"} {
+			archive := testZip(t, map[string]string{name: "evil"})
+			e := &extractor{directory: t.TempDir()}
+			err := e.extractZip(bytes.NewReader(archive), int64(len(archive)))
+			assert.True(t, errors.Is(err, errInvalidArchive), name)
+		}
+	})
+
+	t.Run("size-limit", func(t *testing.T) {
+		archive := testTarGz(t, []*tar.Header{{Name: "bomb", Typeflag: tar.TypeReg, Mode: 0644}}, []string{string(make([]byte, 1024))})
+		e := &extractor{directory: t.TempDir(), maxSize: 1000}
+		assert.True(t, errors.Is(e.extractTarGz(bytes.NewReader(archive)), errInvalidArchive))
+	})
+
+	t.Run("file-limit", func(t *testing.T) {
+		archive := testZip(t, map[string]string{"a": "a", "b": "b", "c": "c"})
+		e := &extractor{directory: t.TempDir(), maxFiles: 2}
+		assert.True(t, errors.Is(e.extractZip(bytes.NewReader(archive), int64(len(archive))), errInvalidArchive))
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		e := &extractor{directory: t.TempDir()}
+		assert.True(t, errors.Is(e.extractTarGz(bytes.NewReader([]byte("not gzip"))), errInvalidArchive))
+	})
+}
+
+// Test the scan of an uploaded archive
+func TestScanArchive(t *testing.T) {
+	archive := testZip(t, map[string]string{"src/keys.go": "var key = \"public_key_1\"", "README.md": "# Vendor drop"})
+	scanRepository := &ScanRepository{
+		SearchPattern:   []string{"public_key"},
+		ScanCloneFolder: t.TempDir(),
+		NoOfWorkers:     2,
+		ExtractMaxSize:  1 << 20,
+		ExtractMaxFiles: 10,
+	}
+	upload := &domain.Upload{ID: 1, Name: "drop.zip", Format: "zip", Size: int64(len(archive))}
+	scanData, err := scanRepository.ScanArchive(context.Background(), upload, bytes.NewReader(archive))
+	assert.NoError(t, err)
+	assert.Equal(t, int8(3), scanData.Status)
+
+	var scanResult result
+	assert.NoError(t, json.Unmarshal([]byte(scanData.Result), &scanResult))
+	if assert.Len(t, scanResult.Findings, 1) {
+		assert.Equal(t, "keys.go", filepath.Base(scanResult.Findings[0].Location.Path))
+	}
+
+	// Scan of the upload stops with its request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = scanRepository.ScanArchive(ctx, upload, bytes.NewReader(archive))
+	assert.ErrorIs(t, err, context.Canceled)
+}
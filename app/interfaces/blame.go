@@ -0,0 +1,159 @@
+package interfaces
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Commit which last changed the line of a finding
+type attribution struct {
+	Commit      string `json:"commit"`
+	AuthorName  string `json:"author_name"`
+	AuthorEmail string `json:"author_email"`
+	CommitTime  string `json:"commit_time"`
+}
+
+// A BlameCache keeps the blame of the recently scanned files, so the findings of the same
+// file and commit are blamed once. The least recently used files are dropped.
+type BlameCache struct {
+	// Maximum number of the blamed files, 0 disables the cache
+	MaxEntries int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type blameEntry struct {
+	key   string
+	lines []attribution
+}
+
+// NewBlameCache returns the blame cache of at most maxEntries files.
+func NewBlameCache(maxEntries int) *BlameCache {
+	return &BlameCache{
+		MaxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (bc *BlameCache) get(key string) ([]attribution, bool) {
+	if bc == nil || bc.MaxEntries <= 0 {
+		return nil, false
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	element, ok := bc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	bc.order.MoveToFront(element)
+	return element.Value.(*blameEntry).lines, true
+}
+
+func (bc *BlameCache) add(key string, lines []attribution) {
+	if bc == nil || bc.MaxEntries <= 0 {
+		return
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if element, ok := bc.entries[key]; ok {
+		bc.order.MoveToFront(element)
+		return
+	}
+	bc.entries[key] = bc.order.PushFront(&blameEntry{key: key, lines: lines})
+	for bc.order.Len() > bc.MaxEntries {
+		oldest := bc.order.Back()
+		bc.order.Remove(oldest)
+		delete(bc.entries, oldest.Value.(*blameEntry).key)
+	}
+}
+
+// Add the commit which last changed each line of the findings to the scan output. The paths of
+// the findings are inside the worktree directory of the repo. The files which can not be blamed,
+// e.g. the files of the submodules, are left without the attribution. Cancelling the context
+// stops the blame between the files.
+func (sr *ScanRepository) blameFindings(ctx context.Context, gitRepo *git.Repository, directory string, output string) (string, error) {
+	var scanOutput result
+	if err := json.Unmarshal([]byte(output), &scanOutput); err != nil {
+		return "", err
+	}
+	if len(scanOutput.Findings) == 0 {
+		return output, nil
+	}
+	head, err := gitRepo.Head()
+	if err != nil {
+		return "", err
+	}
+	commit, err := gitRepo.CommitObject(head.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	commits := make(map[plumbing.Hash]*object.Commit)
+	for i := range scanOutput.Findings {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		f := &scanOutput.Findings[i]
+		rel, err := filepath.Rel(directory, f.Location.Path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		lines, err := sr.blameFile(gitRepo, commit, filepath.ToSlash(rel), commits)
+		if err != nil {
+			continue
+		}
+		for j := range f.Location.Positions {
+			line, err := strconv.Atoi(f.Location.Positions[j].Begin.Line)
+			if err == nil && line >= 1 && line <= len(lines) {
+				lineAttribution := lines[line-1]
+				f.Location.Positions[j].Blame = &lineAttribution
+			}
+		}
+	}
+
+	blamed, err := json.MarshalIndent(scanOutput, "", "  ")
+	return string(blamed), err
+}
+
+// Blame each line of the file at the commit. The commits of the lines are shared between the
+// files of the scan.
+func (sr *ScanRepository) blameFile(gitRepo *git.Repository, commit *object.Commit, path string, commits map[plumbing.Hash]*object.Commit) (lines []attribution, err error) {
+	key := commit.Hash.String() + ":" + path
+	if lines, ok := sr.BlameCache.get(key); ok {
+		return lines, nil
+	}
+	blame, err := git.Blame(commit, path)
+	if err != nil {
+		return
+	}
+	for _, line := range blame.Lines {
+		lineCommit, ok := commits[line.Hash]
+		if !ok {
+			if lineCommit, err = gitRepo.CommitObject(line.Hash); err != nil {
+				return nil, err
+			}
+			commits[line.Hash] = lineCommit
+		}
+		lines = append(lines, attribution{
+			Commit:      line.Hash.String(),
+			AuthorName:  lineCommit.Author.Name,
+			AuthorEmail: lineCommit.Author.Email,
+			CommitTime:  lineCommit.Committer.When.UTC().Format(time.RFC3339),
+		})
+	}
+	sr.BlameCache.add(key, lines)
+	return
+}
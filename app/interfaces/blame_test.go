@@ -0,0 +1,95 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the blame of the findings of a cloned worktree
+func TestScanBlame(t *testing.T) {
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git-upload-pack is required to clone local repos")
+	}
+	source := testGitRepo(t, map[string]string{"config.go": "package config\nvar old = \"public_key_old\"\n"})
+	gitRepo, err := git.PlainOpen(source)
+	assert.NoError(t, err)
+	worktree, err := gitRepo.Worktree()
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(source, "config.go"), []byte("package config\nvar old = \"public_key_old\"\nvar added = \"public_key_new\"\n"), 0644))
+	_, err = worktree.Add("config.go")
+	assert.NoError(t, err)
+	commitTime := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	hash, err := worktree.Commit("add key", &git.CommitOptions{
+		Author: &object.Signature{Name: "Alice", Email: "alice@example.com", When: commitTime},
+	})
+	assert.NoError(t, err)
+
+	mirrorCache, err := NewMirrorCache(t.TempDir(), 0)
+	assert.NoError(t, err)
+	for name, scanRepository := range map[string]*ScanRepository{
+		"clone":  {SearchPattern: []string{"public_key"}, NoOfWorkers: 2, BlameCache: NewBlameCache(10)},
+		"mirror": {SearchPattern: []string{"public_key"}, NoOfWorkers: 2, MirrorCache: mirrorCache},
+	} {
+		t.Run(name, func(t *testing.T) {
+			scanData, err := scanRepository.Scan(context.Background(), &domain.Repo{Url: source}, &domain.ScanOptions{Blame: true})
+			assert.NoError(t, err)
+			assert.Equal(t, int8(3), scanData.Status)
+
+			var scanResult result
+			assert.NoError(t, json.Unmarshal([]byte(scanData.Result), &scanResult))
+			if assert.Len(t, scanResult.Findings, 1) && assert.Len(t, scanResult.Findings[0].Location.Positions, 2) {
+				positions := scanResult.Findings[0].Location.Positions
+				if assert.NotNil(t, positions[0].Blame) {
+					assert.Equal(t, "Test", positions[0].Blame.AuthorName)
+				}
+				assert.Equal(t, &attribution{
+					Commit:      hash.String(),
+					AuthorName:  "Alice",
+					AuthorEmail: "alice@example.com",
+					CommitTime:  commitTime.Format(time.RFC3339),
+				}, positions[1].Blame)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		scanRepository := &ScanRepository{SearchPattern: []string{"public_key"}, NoOfWorkers: 1}
+		scanData, err := scanRepository.Scan(context.Background(), &domain.Repo{Url: source}, nil)
+		assert.NoError(t, err)
+		assert.NotContains(t, scanData.Result, "blame")
+	})
+}
+
+// Test the eviction of the least recently used files
+func TestBlameCache(t *testing.T) {
+	blameCache := NewBlameCache(2)
+	blameCache.add("a", []attribution{{Commit: "1"}})
+	blameCache.add("b", []attribution{{Commit: "2"}})
+	_, ok := blameCache.get("a")
+	assert.True(t, ok)
+	blameCache.add("c", []attribution{{Commit: "3"}})
+
+	_, ok = blameCache.get("b")
+	assert.False(t, ok)
+	lines, ok := blameCache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", lines[0].Commit)
+	_, ok = blameCache.get("c")
+	assert.True(t, ok)
+
+	// Disabled cache keeps nothing
+	var disabled *BlameCache
+	disabled.add("a", nil)
+	_, ok = disabled.get("a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,120 @@
+package interfaces
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+)
+
+// Struct for the certificate details of a finding
+type certificate struct {
+	Subject    string   `json:"subject"`
+	Issuer     string   `json:"issuer"`
+	SANs       []string `json:"sans,omitempty"`
+	Serial     string   `json:"serial"`
+	NotBefore  string   `json:"not_before"`
+	NotAfter   string   `json:"not_after"`
+	SelfSigned bool     `json:"self_signed"`
+	Expired    bool     `json:"expired"`
+}
+
+var pemCertificateHeader = []byte("-----BEGIN CERTIFICATE-----")
+
+// A certificateDetector reports the PEM and DER encoded X.509 certificates.
+type certificateDetector struct {
+	now func() time.Time
+}
+
+func (cd *certificateDetector) detect(path string, content []byte) (fileFindings findings) {
+	now := time.Now().UTC()
+	if cd.now != nil {
+		now = cd.now()
+	}
+	// DER certificates are binary, so the whole file has to be the certificate
+	if len(content) > 1 && content[0] == 0x30 && content[1] >= 0x80 {
+		if cert, err := x509.ParseCertificate(content); err == nil {
+			return findings{certificateFinding(path, position{Begin: begin{Line: "1", Cols: []string{"1"}}}, cert, now)}
+		}
+	}
+
+	offset := 0
+	for {
+		idx := bytes.Index(content[offset:], pemCertificateHeader)
+		if idx < 0 {
+			return
+		}
+		start := offset + idx
+		block, rest := pem.Decode(content[start:])
+		// Malformed block, continue after the header
+		if block == nil {
+			offset = start + len(pemCertificateHeader)
+			continue
+		}
+		offset = len(content) - len(rest)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		fileFindings = append(fileFindings, certificateFinding(path, positionAt(content, start), cert, now))
+	}
+}
+
+// Build the finding of a parsed certificate
+func certificateFinding(path string, pos position, cert *x509.Certificate, now time.Time) finding {
+	details := &certificate{
+		Subject:    cert.Subject.String(),
+		Issuer:     cert.Issuer.String(),
+		SANs:       certificateSANs(cert),
+		Serial:     fmt.Sprintf("%X", cert.SerialNumber),
+		NotBefore:  cert.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:   cert.NotAfter.UTC().Format(time.RFC3339),
+		SelfSigned: isSelfSigned(cert),
+		Expired:    now.After(cert.NotAfter),
+	}
+	md := metadata{
+		Description: "X.509 certificate is present",
+		Severity:    "INFO",
+		Certificate: details,
+	}
+	switch {
+	case details.Expired:
+		md.Description = "Expired X.509 certificate is present"
+		md.Severity = "HIGH"
+	case details.SelfSigned:
+		md.Description = "Self-signed X.509 certificate is present"
+		md.Severity = "LOW"
+	}
+	return finding{
+		ErrorType: "sast",
+		RuleID:    "G403",
+		Location: location{
+			Path:      path,
+			Positions: []position{pos},
+		},
+		Metadata: md,
+	}
+}
+
+// Collect all the subject alternative names of the certificate
+func certificateSANs(cert *x509.Certificate) (sans []string) {
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return
+}
+
+// Check if the certificate is signed by its own key. CheckSignatureFrom is not used
+// because it rejects the self-signed leaf certificates which are not a CA.
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
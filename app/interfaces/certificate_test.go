@@ -0,0 +1,64 @@
+package interfaces
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Create a self-signed certificate valid until the given time
+func testCertificate(t *testing.T, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(4242),
+		Subject:      pkix.Name{CommonName: "test.example.com"},
+		DNSNames:     []string{"test.example.com"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	return der
+}
+
+// Test certificate detection
+func TestCertificateDetector(t *testing.T) {
+	now := time.Now().UTC()
+	cd := &certificateDetector{now: func() time.Time { return now }}
+
+	t.Run("pem", func(t *testing.T) {
+		der := testCertificate(t, now.Add(24*time.Hour))
+		content := append([]byte("config:\n  cert: "), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+		results := cd.detect("test.yaml", content)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "G403", results[0].RuleID)
+		assert.Equal(t, "2", results[0].Location.Positions[0].Begin.Line)
+		assert.Equal(t, []string{"9"}, results[0].Location.Positions[0].Begin.Cols)
+		assert.Equal(t, "CN=test.example.com", results[0].Metadata.Certificate.Subject)
+		assert.Equal(t, []string{"test.example.com"}, results[0].Metadata.Certificate.SANs)
+		assert.Equal(t, "1092", results[0].Metadata.Certificate.Serial)
+		assert.True(t, results[0].Metadata.Certificate.SelfSigned)
+		assert.Equal(t, "LOW", results[0].Metadata.Severity)
+	})
+
+	t.Run("der-expired", func(t *testing.T) {
+		results := cd.detect("test.der", testCertificate(t, now.Add(-time.Hour)))
+		assert.Equal(t, 1, len(results))
+		assert.True(t, results[0].Metadata.Certificate.Expired)
+		assert.Equal(t, "HIGH", results[0].Metadata.Severity)
+	})
+
+	t.Run("notfound", func(t *testing.T) {
+		results := cd.detect("test.txt", []byte("-----BEGIN CERTIFICATE-----\nnot a certificate\n"))
+		assert.Equal(t, 0, len(results))
+	})
+}
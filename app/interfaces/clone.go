@@ -0,0 +1,234 @@
+package interfaces
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/scanner/app/domain"
+)
+
+// Fill in the defaults of the clone strategy. Without a strategy the full repo
+// is cloned with all the tags and the submodules recursively.
+func effectiveStrategy(strategy *domain.CloneStrategy) *domain.CloneStrategy {
+	if strategy == nil {
+		return &domain.CloneStrategy{Tags: true, SubmoduleMode: "recursive"}
+	}
+	effective := *strategy
+	if effective.SubmoduleMode == "" {
+		effective.SubmoduleMode = "recursive"
+	}
+	return &effective
+}
+
+// Build the clone options of the repo with the given strategy
+func cloneOptions(repo *domain.Repo, strategy *domain.CloneStrategy, auth transport.AuthMethod) *git.CloneOptions {
+	options := &git.CloneOptions{
+		URL:          repo.Url,
+		Auth:         auth,
+		SingleBranch: strategy.SingleBranch,
+		Depth:        strategy.Depth,
+		Tags:         git.NoTags,
+	}
+	if strategy.Branch != "" {
+		options.ReferenceName = plumbing.NewBranchReferenceName(strategy.Branch)
+	}
+	if strategy.Tags {
+		options.Tags = git.AllTags
+	}
+	return options
+}
+
+// Clone the repo into the directory with the given strategy
+func (sr *ScanRepository) clone(ctx context.Context, directory string, repo *domain.Repo, strategy *domain.CloneStrategy, auth transport.AuthMethod) (gitRepo *git.Repository, err error) {
+	options := cloneOptions(repo, strategy, auth)
+	// Sparse paths are checked out after the clone
+	options.NoCheckout = len(strategy.SparsePaths) > 0
+	gitRepo, err = git.PlainCloneContext(ctx, directory, false, options)
+	if err != nil {
+		return
+	}
+
+	worktree, err := gitRepo.Worktree()
+	if err != nil {
+		return
+	}
+	if len(strategy.SparsePaths) > 0 {
+		var head *plumbing.Reference
+		head, err = gitRepo.Head()
+		if err != nil {
+			return
+		}
+		if err = checkoutSparse(gitRepo, worktree, head.Hash(), strategy.SparsePaths); err != nil {
+			return
+		}
+	}
+
+	err = updateSubmodules(ctx, worktree, repo.Url, strategy, auth, 1)
+	return
+}
+
+// Check out only the files of the sparse paths. The sparse checkout of go-git checks out
+// all the files of a fresh clone, so the files are written from the commit tree instead.
+func checkoutSparse(gitRepo *git.Repository, worktree *git.Worktree, hash plumbing.Hash, sparsePaths []string) error {
+	// The index is needed for the commits of the submodules
+	if err := worktree.Reset(&git.ResetOptions{Commit: hash, Mode: git.MixedReset}); err != nil {
+		return err
+	}
+	commit, err := gitRepo.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	return tree.Files().ForEach(func(file *object.File) error {
+		// Symlinks are not followed, .gitmodules is needed for the submodules
+		if file.Mode == filemode.Symlink || (file.Name != ".gitmodules" && !inSparsePaths(file.Name, sparsePaths)) {
+			return nil
+		}
+		reader, err := file.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		out, err := worktree.Filesystem.Create(file.Name)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		_, err = io.Copy(out, reader)
+		return err
+	})
+}
+
+// Check if the path is inside any of the sparse paths
+func inSparsePaths(path string, sparsePaths []string) bool {
+	for _, sparsePath := range sparsePaths {
+		sparsePath = strings.Trim(sparsePath, "/")
+		if path == sparsePath || strings.HasPrefix(path, sparsePath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone the submodules of the worktree according to the submodule mode
+func updateSubmodules(ctx context.Context, worktree *git.Worktree, parentUrl string, strategy *domain.CloneStrategy, auth transport.AuthMethod, depth int) error {
+	if strategy.SubmoduleMode == "none" || depth > int(git.DefaultSubmoduleRecursionDepth) {
+		return nil
+	}
+	submodules, err := worktree.Submodules()
+	if err != nil {
+		return err
+	}
+	parentHost := gitHost(parentUrl)
+	for _, submodule := range submodules {
+		// Only the submodules of the sparse paths are cloned
+		if depth == 1 && len(strategy.SparsePaths) > 0 && !inSparsePaths(submodule.Config().Path, strategy.SparsePaths) {
+			continue
+		}
+		host := gitHost(submodule.Config().URL)
+		// Relative submodules are on the same host as the parent
+		if host == "" {
+			host = parentHost
+		}
+		if strategy.SubmoduleMode == "allowlist" && !hostAllowed(host, strategy.SubmoduleHosts) {
+			continue
+		}
+		options := &git.SubmoduleUpdateOptions{Init: true}
+		// Credentials of the repo are never sent to the other hosts
+		if host == parentHost {
+			options.Auth = auth
+		}
+		if err = submodule.UpdateContext(ctx, options); err != nil {
+			return err
+		}
+		if strategy.SubmoduleMode == "top-level" {
+			continue
+		}
+
+		subRepo, err := submodule.Repository()
+		if err != nil {
+			return err
+		}
+		subWorktree, err := subRepo.Worktree()
+		if err != nil {
+			return err
+		}
+		if err = updateSubmodules(ctx, subWorktree, submodule.Config().URL, strategy, auth, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return the host of the git URL, empty for the relative URLs
+func gitHost(gitUrl string) string {
+	if strings.HasPrefix(gitUrl, "./") || strings.HasPrefix(gitUrl, "../") {
+		return ""
+	}
+	endpoint, err := transport.NewEndpoint(gitUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(endpoint.Host)
+}
+
+// Check if the host is in the allowlist
+func hostAllowed(host string, allowlist []string) bool {
+	for _, allowed := range allowlist {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the sparse path is a directory inside the repo
+func validSparsePath(path string) bool {
+	if path == "" || strings.HasPrefix(path, "/") {
+		return false
+	}
+	u, err := url.Parse(path)
+	if err != nil || u.Scheme != "" {
+		return false
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode the clone strategy for the JSON column, nil is stored as NULL
+func encodeStrategy(strategy *domain.CloneStrategy) (encoded sql.NullString, err error) {
+	if strategy == nil {
+		return
+	}
+	data, err := json.Marshal(strategy)
+	if err != nil {
+		return
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// Decode the clone strategy of the JSON column
+func decodeStrategy(encoded sql.NullString) (strategy *domain.CloneStrategy, err error) {
+	if encoded.String == "" {
+		return
+	}
+	strategy = &domain.CloneStrategy{}
+	err = json.Unmarshal([]byte(encoded.String), strategy)
+	return
+}
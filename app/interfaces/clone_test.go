@@ -0,0 +1,153 @@
+package interfaces
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Create a local git repo with a commit of the given files
+func testGitRepo(t *testing.T, files map[string]string) string {
+	directory := t.TempDir()
+	gitRepo, err := git.PlainInit(directory, false)
+	assert.NoError(t, err)
+	worktree, err := gitRepo.Worktree()
+	assert.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(directory, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err = worktree.Add(name)
+		assert.NoError(t, err)
+	}
+	_, err = worktree.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+	return directory
+}
+
+// Test the clone strategies
+func TestScanClone(t *testing.T) {
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git-upload-pack is required to clone local repos")
+	}
+	source := testGitRepo(t, map[string]string{
+		"src/main.go":   "package main",
+		"docs/guide.md": "# Guide",
+	})
+	scanRepository := &ScanRepository{}
+
+	t.Run("sparse", func(t *testing.T) {
+		directory := t.TempDir()
+		strategy := effectiveStrategy(&domain.CloneStrategy{Depth: 1, SingleBranch: true, SparsePaths: []string{"src"}})
+		_, err := scanRepository.clone(context.Background(), directory, &domain.Repo{Url: source}, strategy, nil)
+		assert.NoError(t, err)
+		assert.FileExists(t, filepath.Join(directory, "src", "main.go"))
+		assert.NoFileExists(t, filepath.Join(directory, "docs", "guide.md"))
+	})
+
+	t.Run("full", func(t *testing.T) {
+		directory := t.TempDir()
+		_, err := scanRepository.clone(context.Background(), directory, &domain.Repo{Url: source}, effectiveStrategy(nil), nil)
+		assert.NoError(t, err)
+		assert.FileExists(t, filepath.Join(directory, "docs", "guide.md"))
+	})
+}
+
+// Test the scan of a cancelled context, the clone directory is deleted
+func TestScanCancelled(t *testing.T) {
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git-upload-pack is required to clone local repos")
+	}
+	source := testGitRepo(t, map[string]string{"main.go": "package main"})
+	cloneFolder := t.TempDir()
+	scanRepository := &ScanRepository{ScanCloneFolder: cloneFolder, NoOfWorkers: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := scanRepository.Scan(ctx, &domain.Repo{Url: source}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	entries, err := os.ReadDir(cloneFolder)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// Test the clone strategy helpers
+func TestCloneStrategyHelpers(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		assert.Equal(t, &domain.CloneStrategy{Tags: true, SubmoduleMode: "recursive"}, effectiveStrategy(nil))
+		assert.Equal(t, "none", effectiveStrategy(&domain.CloneStrategy{SubmoduleMode: "none"}).SubmoduleMode)
+	})
+
+	t.Run("hosts", func(t *testing.T) {
+		assert.Equal(t, "github.com", gitHost("https://github.com/test/test.git"))
+		assert.Equal(t, "github.com", gitHost("git@GitHub.com:test/test.git"))
+		assert.Equal(t, "", gitHost("../other.git"))
+		assert.True(t, hostAllowed("github.com", []string{"GitHub.com"}))
+		assert.False(t, hostAllowed("evil.example.com", []string{"github.com"}))
+	})
+
+	t.Run("sparse-paths", func(t *testing.T) {
+		assert.True(t, validSparsePath("src/app"))
+		assert.False(t, validSparsePath("/etc"))
+		assert.False(t, validSparsePath("src/../../etc"))
+	})
+}
+
+// Test the sweep of the clone directories which no scan uses
+func TestSweepClones(t *testing.T) {
+	folder := t.TempDir()
+	scanRepository := &ScanRepository{ScanCloneFolder: folder, ScanCloneFolderPrefix: "repo", Owner: "host-1-ab"}
+	orphaned := filepath.Join(folder, "repohost-1-ab-123")
+	assert.NoError(t, os.MkdirAll(filepath.Join(orphaned, "src"), 0755))
+	// Clone folder is shared with a live and a dead instance
+	live := filepath.Join(folder, "repohost-2-cd-456")
+	dead := filepath.Join(folder, "repohost-3-ef-789")
+	for _, directory := range []string{live, dead} {
+		assert.NoError(t, os.Mkdir(directory, 0755))
+	}
+	// Only the directories of the prefix, an owner and a random number are clone directories
+	for _, name := range []string{"repository", "repo", "repo123", "other-123"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(folder, name), 0755))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(folder, "repohost-3-ef-1"), nil, 0644))
+	running, remove, err := scanRepository.cloneDir()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(filepath.Base(running), "repohost-1-ab-"))
+
+	removed, err := scanRepository.sweepClones(map[string]struct{}{"host-1-ab": {}, "host-2-cd": {}})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{orphaned, dead}, removed)
+	assert.NoDirExists(t, orphaned)
+	assert.NoDirExists(t, dead)
+	assert.DirExists(t, running)
+	assert.DirExists(t, live)
+	for _, name := range []string{"repository", "repo", "repo123", "other-123"} {
+		assert.DirExists(t, filepath.Join(folder, name))
+	}
+	assert.FileExists(t, filepath.Join(folder, "repohost-3-ef-1"))
+
+	// Directory of the finished scan is removed by the scan
+	remove()
+	assert.NoDirExists(t, running)
+	removed, err = scanRepository.sweepClones(map[string]struct{}{"host-2-cd": {}})
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+
+	t.Run("no-prefix", func(t *testing.T) {
+		removed, err := (&ScanRepository{ScanCloneFolder: folder}).SweepClones(time.Minute)
+		assert.NoError(t, err)
+		assert.Empty(t, removed)
+		assert.DirExists(t, filepath.Join(folder, "repository"))
+	})
+}
@@ -0,0 +1,138 @@
+package interfaces
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Struct for the connection details of a finding. The credentials are never recorded.
+type connection struct {
+	Scheme string `json:"scheme"`
+	Host   string `json:"host"`
+}
+
+var (
+	// URL with a scheme, optionally prefixed by jdbc:
+	connectionURLPattern = regexp.MustCompile("(?i)(?:jdbc:)?[a-z][a-z0-9+.-]*://[^\\s'\"<>`]+")
+	// MySQL DSN of go-sql-driver, e.g. user:pass@tcp(host:3306)/db
+	mysqlDSNPattern = regexp.MustCompile(`([A-Za-z0-9_.-]+):([^\s@'"]*)@(tcp6?|udp|unix)\(([^)\s]*)\)`)
+	// Password parameter of JDBC and ADO style connection strings
+	passwordParamPattern = regexp.MustCompile(`(?i)(?:^|[;&?])(?:password|pwd)=([^;&]*)`)
+	// Templating and variable references which are filled in at runtime
+	placeholderPattern = regexp.MustCompile(`^(?:\$\{.*\}|\$\(.*\)|\{\{.*\}\}|<.*>|%.*|\$[A-Za-z_][A-Za-z0-9_]*|[x*.#_-]+|\[.*\])$`)
+)
+
+// Common dummy passwords of documentation and sample configuration
+var placeholderPasswords = map[string]bool{
+	"password":      true,
+	"passwd":        true,
+	"pass":          true,
+	"pwd":           true,
+	"secret":        true,
+	"changeme":      true,
+	"example":       true,
+	"test":          true,
+	"dummy":         true,
+	"your_password": true,
+	"yourpassword":  true,
+	"mypassword":    true,
+}
+
+// A connectionDetector reports the connection strings and URLs which contain a password.
+type connectionDetector struct{}
+
+func (cd *connectionDetector) detect(path string, content []byte) (fileFindings findings) {
+	urlLocs := connectionURLPattern.FindAllIndex(content, -1)
+	for _, loc := range urlLocs {
+		raw := strings.TrimRight(string(content[loc[0]:loc[1]]), ".,;)]}")
+		if details, ok := parseConnectionURL(raw); ok {
+			fileFindings = append(fileFindings, connectionFinding(path, content, loc[0], details))
+		}
+	}
+
+	for _, loc := range mysqlDSNPattern.FindAllSubmatchIndex(content, -1) {
+		// Already reported as part of a URL
+		if overlaps(urlLocs, loc[0], loc[1]) {
+			continue
+		}
+		password := string(content[loc[4]:loc[5]])
+		if isPlaceholder(password) {
+			continue
+		}
+		fileFindings = append(fileFindings, connectionFinding(path, content, loc[0], &connection{
+			Scheme: "mysql",
+			Host:   string(content[loc[8]:loc[9]]),
+		}))
+	}
+	return
+}
+
+// Parse the URL and return its scheme and host if it has a real password
+func parseConnectionURL(raw string) (details *connection, ok bool) {
+	scheme := ""
+	body := raw
+	if strings.HasPrefix(strings.ToLower(raw), "jdbc:") {
+		scheme = "jdbc:"
+		body = raw[len("jdbc:"):]
+	}
+	// SQL Server JDBC URLs have the properties after a semicolon
+	var params string
+	if idx := strings.Index(body, ";"); idx >= 0 {
+		body, params = body[:idx], body[idx:]
+	}
+	u, err := url.Parse(body)
+	if err != nil || u.Host == "" {
+		return
+	}
+
+	password, _ := u.User.Password()
+	if password == "" {
+		password = u.Query().Get("password")
+	}
+	if password == "" {
+		if m := passwordParamPattern.FindStringSubmatch(params); m != nil {
+			password = m[1]
+		}
+	}
+	if isPlaceholder(password) {
+		return
+	}
+	return &connection{Scheme: scheme + strings.ToLower(u.Scheme), Host: u.Host}, true
+}
+
+// Check if the password is empty or a placeholder
+func isPlaceholder(password string) bool {
+	password = strings.TrimSpace(password)
+	if password == "" {
+		return true
+	}
+	return placeholderPasswords[strings.ToLower(password)] || placeholderPattern.MatchString(password)
+}
+
+// Check if the range overlaps any of the given locations
+func overlaps(locs [][]int, start, end int) bool {
+	for _, loc := range locs {
+		if start < loc[1] && loc[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// Build the finding of a connection string
+func connectionFinding(path string, content []byte, offset int, details *connection) finding {
+	return finding{
+		ErrorType: "sast",
+		RuleID:    "G405",
+		Location: location{
+			Path:      path,
+			Positions: []position{positionAt(content, offset)},
+		},
+		Metadata: metadata{
+			Description: "Credentials are present in connection string",
+			Severity:    "HIGH",
+			Connection:  details,
+		},
+	}
+}
@@ -0,0 +1,156 @@
+package interfaces
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/scanner/app/domain"
+	"golang.org/x/crypto/ssh"
+)
+
+var errCredentialKey = errors.New("CREDENTIAL_KEY is not configured")
+
+// Load the AES-256 key of the credential store from the base64 encoded CREDENTIAL_KEY
+func loadCredentialKey() ([]byte, error) {
+	encoded := os.Getenv("CREDENTIAL_KEY")
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, errors.New("CREDENTIAL_KEY must be 32 bytes")
+	}
+	return key, nil
+}
+
+// Encrypt the credential with AES-GCM, the nonce is prepended to the sealed data
+func encryptCredential(key []byte, credential *domain.Credential) (string, error) {
+	if len(key) == 0 {
+		return "", errCredentialKey
+	}
+	plaintext, err := json.Marshal(credential)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// Decrypt the credential which is encrypted by encryptCredential
+func decryptCredential(key []byte, encrypted string) (*domain.Credential, error) {
+	if len(key) == 0 {
+		return nil, errCredentialKey
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("credential is corrupted")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	credential := &domain.Credential{}
+	err = json.Unmarshal(plaintext, credential)
+	return credential, err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Validation rule of an SSH private key which is encrypted with the passphrase
+func validPrivateKey(passphrase string) validation.RuleFunc {
+	return func(value interface{}) error {
+		privateKey, _ := value.(string)
+		_, err := gitssh.NewPublicKeys("git", []byte(privateKey), passphrase)
+		if err != nil {
+			return errors.New("must be a valid private key")
+		}
+		return nil
+	}
+}
+
+// Build the go-git auth method of the credential, nil credential clones anonymously
+func cloneAuth(credential *domain.Credential) (auth transport.AuthMethod, err error) {
+	if credential == nil {
+		return
+	}
+	switch credential.Type {
+	case "https":
+		username := credential.Username
+		// Token based auth of most git hosts accepts any non-empty user name
+		if username == "" {
+			username = "git"
+		}
+		auth = &githttp.BasicAuth{Username: username, Password: credential.Password}
+	case "ssh":
+		username := credential.Username
+		if username == "" {
+			username = "git"
+		}
+		var keys *gitssh.PublicKeys
+		keys, err = gitssh.NewPublicKeys(username, []byte(credential.PrivateKey), credential.Passphrase)
+		if err != nil {
+			return
+		}
+		keys.HostKeyCallback, err = hostKeyCallback(credential)
+		if err != nil {
+			return
+		}
+		auth = keys
+	}
+	return
+}
+
+// Build the host key verification of the known_hosts policy
+func hostKeyCallback(credential *domain.Credential) (ssh.HostKeyCallback, error) {
+	if credential.KnownHostsPolicy == "insecure" {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	// Without the known hosts of the repo, the system known_hosts files are used
+	if credential.KnownHosts == "" {
+		return gitssh.NewKnownHostsCallback()
+	}
+	file, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return nil, err
+	}
+	// The callback reads the file while it is created
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if _, err = file.WriteString(credential.KnownHosts); err != nil {
+		return nil, err
+	}
+	return gitssh.NewKnownHostsCallback(file.Name())
+}
@@ -0,0 +1,86 @@
+package interfaces
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Create an unencrypted PKCS#8 private key
+func testPrivateKey(t *testing.T) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// Test encryption of the credentials
+func TestCredentialEncryption(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	assert.NoError(t, err)
+	credential := &domain.Credential{Type: "https", Username: "ci", Password: "ghp_secret"}
+
+	t.Run("roundtrip", func(t *testing.T) {
+		encrypted, err := encryptCredential(key, credential)
+		assert.NoError(t, err)
+		assert.NotContains(t, encrypted, "ghp_secret")
+		decrypted, err := decryptCredential(key, encrypted)
+		assert.NoError(t, err)
+		assert.Equal(t, credential, decrypted)
+	})
+
+	t.Run("wrong-key", func(t *testing.T) {
+		encrypted, err := encryptCredential(key, credential)
+		assert.NoError(t, err)
+		_, err = decryptCredential(make([]byte, 32), encrypted)
+		assert.Error(t, err)
+	})
+
+	t.Run("no-key", func(t *testing.T) {
+		_, err := encryptCredential(nil, credential)
+		assert.Equal(t, errCredentialKey, err)
+	})
+}
+
+// Test the clone auth of the credentials
+func TestCloneAuth(t *testing.T) {
+	t.Run("anonymous", func(t *testing.T) {
+		auth, err := cloneAuth(nil)
+		assert.NoError(t, err)
+		assert.Nil(t, auth)
+	})
+
+	t.Run("https", func(t *testing.T) {
+		auth, err := cloneAuth(&domain.Credential{Type: "https", Password: "token"})
+		assert.NoError(t, err)
+		assert.Equal(t, &githttp.BasicAuth{Username: "git", Password: "token"}, auth)
+	})
+
+	t.Run("ssh", func(t *testing.T) {
+		auth, err := cloneAuth(&domain.Credential{
+			Type:             "ssh",
+			PrivateKey:       testPrivateKey(t),
+			KnownHostsPolicy: "strict",
+			KnownHosts:       "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl",
+		})
+		assert.NoError(t, err)
+		keys, ok := auth.(*gitssh.PublicKeys)
+		assert.True(t, ok)
+		assert.Equal(t, "git", keys.User)
+		assert.NotNil(t, keys.HostKeyCallback)
+	})
+
+	t.Run("ssh-invalid-key", func(t *testing.T) {
+		_, err := cloneAuth(&domain.Credential{Type: "ssh", PrivateKey: "invalid"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,182 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	// Time zones of the schedules are available without the zoneinfo of the host
+	_ "time/tzdata"
+
+	"github.com/scanner/app/domain"
+)
+
+// A cronSchedule is the parsed cron expression of a schedule with its fields as bit sets.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Day of month and day of week match either of them when both are restricted
+	domAny, dowAny bool
+	location       *time.Location
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	// 7 is sunday as well
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse the cron expression of the schedule in its time zone, UTC when it is not given. The
+// expression has the minute, hour, day of month, month and day of week fields, or is a macro
+// such as @daily.
+func parseSchedule(schedule *domain.Schedule) (*cronSchedule, error) {
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("timezone: %w", err)
+	}
+	expression := strings.TrimSpace(schedule.Cron)
+	if macro, ok := cronMacros[strings.ToLower(expression)]; ok {
+		expression = macro
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, errors.New("schedule: must have 5 fields")
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, field := range fields {
+		if sets[i], err = parseCronField(field, cronFields[i]); err != nil {
+			return nil, fmt.Errorf("schedule: %s: %w", cronFields[i].name, err)
+		}
+	}
+	// Sunday is 0 or 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cronSchedule{
+		minute:   sets[0],
+		hour:     sets[1],
+		dom:      sets[2],
+		month:    sets[3],
+		dow:      sets[4],
+		domAny:   strings.HasPrefix(fields[2], "*"),
+		dowAny:   strings.HasPrefix(fields[4], "*"),
+		location: location,
+	}, nil
+}
+
+// Parse the comma separated values, ranges and steps of the field into a bit set
+func parseCronField(field string, spec cronField) (set uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rangePart = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+		}
+
+		var low, high int
+		switch {
+		case rangePart == "*":
+			low, high = spec.min, spec.max
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			if low, err = cronValue(bounds[0], spec); err != nil {
+				return
+			}
+			if high, err = cronValue(bounds[1], spec); err != nil {
+				return
+			}
+		default:
+			if low, err = cronValue(rangePart, spec); err != nil {
+				return
+			}
+			high = low
+			// A single value with a step runs until the end of the field
+			if step > 1 {
+				high = spec.max
+			}
+		}
+		if low > high {
+			return 0, fmt.Errorf("invalid range %q", rangePart)
+		}
+		for value := low; value <= high; value += step {
+			set |= 1 << value
+		}
+	}
+	return
+}
+
+// Value of the field, a number or a name of a month or a day
+func cronValue(value string, spec cronField) (int, error) {
+	if number, ok := spec.names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < spec.min || number > spec.max {
+		return 0, fmt.Errorf("%q is not between %d and %d", value, spec.min, spec.max)
+	}
+	return number, nil
+}
+
+// Next time after t which the schedule matches, zero when there is none within 5 years
+func (cs *cronSchedule) next(t time.Time) time.Time {
+	t = t.In(cs.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		var following time.Time
+		switch {
+		case cs.month&(1<<uint(t.Month())) == 0:
+			following = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, cs.location)
+		case !cs.dayMatches(t):
+			following = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, cs.location)
+		case cs.hour&(1<<uint(t.Hour())) == 0:
+			following = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, cs.location).Add(time.Hour)
+		case cs.minute&(1<<uint(t.Minute())) == 0:
+			following = t.Add(time.Minute)
+		default:
+			return t.UTC()
+		}
+		// Midnight which is skipped by the daylight saving time is moved back an hour
+		if !following.After(t) {
+			following = t.Add(time.Hour)
+		}
+		t = following
+	}
+	return time.Time{}
+}
+
+// Match the day of month and the day of week, either of them when both are restricted
+func (cs *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := cs.dom&(1<<uint(t.Day())) != 0
+	dowMatch := cs.dow&(1<<uint(t.Weekday())) != 0
+	if cs.domAny || cs.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
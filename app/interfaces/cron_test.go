@@ -0,0 +1,56 @@
+package interfaces
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the next times of the cron expressions
+func TestCronNext(t *testing.T) {
+	from := time.Date(2023, time.March, 10, 10, 7, 30, 0, time.UTC) // Friday
+	for name, test := range map[string]struct {
+		schedule domain.Schedule
+		next     time.Time
+	}{
+		"step":          {domain.Schedule{Cron: "*/15 * * * *"}, time.Date(2023, time.March, 10, 10, 15, 0, 0, time.UTC)},
+		"daily":         {domain.Schedule{Cron: "@daily"}, time.Date(2023, time.March, 11, 0, 0, 0, 0, time.UTC)},
+		"weekdays":      {domain.Schedule{Cron: "30 9 * * mon-fri"}, time.Date(2023, time.March, 13, 9, 30, 0, 0, time.UTC)},
+		"sunday-7":      {domain.Schedule{Cron: "0 0 * * 7"}, time.Date(2023, time.March, 12, 0, 0, 0, 0, time.UTC)},
+		"list":          {domain.Schedule{Cron: "0 8,20 * * *"}, time.Date(2023, time.March, 10, 20, 0, 0, 0, time.UTC)},
+		"month-name":    {domain.Schedule{Cron: "0 0 1 jun *"}, time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		"day-or-week":   {domain.Schedule{Cron: "0 0 15 * sat"}, time.Date(2023, time.March, 11, 0, 0, 0, 0, time.UTC)},
+		"leap-day":      {domain.Schedule{Cron: "0 0 29 2 *"}, time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		"timezone":      {domain.Schedule{Cron: "0 2 * * *", Timezone: "Asia/Kolkata"}, time.Date(2023, time.March, 10, 20, 30, 0, 0, time.UTC)},
+		"daylight-time": {domain.Schedule{Cron: "0 9 12 3 *", Timezone: "America/New_York"}, time.Date(2023, time.March, 12, 13, 0, 0, 0, time.UTC)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cron, err := parseSchedule(&test.schedule)
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.next, cron.next(from))
+			}
+		})
+	}
+
+	// Date which does not exist never matches
+	cron, err := parseSchedule(&domain.Schedule{Cron: "0 0 31 2 *"})
+	assert.NoError(t, err)
+	assert.True(t, cron.next(from).IsZero())
+}
+
+// Test the invalid cron expressions
+func TestCronInvalid(t *testing.T) {
+	for _, schedule := range []domain.Schedule{
+		{Cron: "* * * *"},
+		{Cron: "60 * * * *"},
+		{Cron: "* 5-2 * * *"},
+		{Cron: "*/0 * * * *"},
+		{Cron: "* * * foo *"},
+		{Cron: "@daily", Timezone: "Mars/Olympus"},
+	} {
+		_, err := parseSchedule(&schedule)
+		assert.Error(t, err, schedule.Cron)
+	}
+}
@@ -0,0 +1,89 @@
+package interfaces
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+)
+
+var (
+	// Base64 (standard and URL safe) and hex tokens which are long enough to hold a secret
+	base64TokenPattern = regexp.MustCompile(`[A-Za-z0-9+/_-]{24,}={0,2}`)
+	hexTokenPattern    = regexp.MustCompile(`^(?:[0-9A-Fa-f]{2}){16,}$`)
+	// Percent-encoded tokens with at least three escapes
+	percentTokenPattern = regexp.MustCompile(`[A-Za-z0-9._~+-]*(?:%[0-9A-Fa-f]{2}[A-Za-z0-9._~+-]*){3,}`)
+)
+
+// Struct for an encoded token and its decoded payload
+type decodedToken struct {
+	offset   int
+	encoding string
+	content  []byte
+}
+
+// Find the encoded tokens in the content and decode them
+func (sr *ScanRepository) decodeTokens(content []byte) (tokens []decodedToken) {
+	for _, loc := range base64TokenPattern.FindAllIndex(content, -1) {
+		raw := content[loc[0]:loc[1]]
+		// Decoded payload is 3/4 of the base64 and 1/2 of the hex token
+		if len(raw)/2 > sr.DecodeMaxSize {
+			continue
+		}
+		if hexTokenPattern.Match(raw) {
+			if decoded, err := hex.DecodeString(string(raw)); err == nil {
+				tokens = append(tokens, decodedToken{offset: loc[0], encoding: "hex", content: decoded})
+			}
+			continue
+		}
+		if decoded, ok := decodeBase64(raw); ok && len(decoded) <= sr.DecodeMaxSize {
+			tokens = append(tokens, decodedToken{offset: loc[0], encoding: "base64", content: decoded})
+		}
+	}
+	for _, loc := range percentTokenPattern.FindAllIndex(content, -1) {
+		raw := content[loc[0]:loc[1]]
+		if len(raw) > sr.DecodeMaxSize {
+			continue
+		}
+		if decoded, err := url.QueryUnescape(string(raw)); err == nil {
+			tokens = append(tokens, decodedToken{offset: loc[0], encoding: "url", content: []byte(decoded)})
+		}
+	}
+	return
+}
+
+// Decode the token with any of the base64 alphabets
+func decodeBase64(raw []byte) ([]byte, bool) {
+	encodings := []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding}
+	for _, enc := range encodings {
+		decoded := make([]byte, enc.DecodedLen(len(raw)))
+		n, err := enc.Decode(decoded, raw)
+		if err == nil {
+			return decoded[:n], true
+		}
+	}
+	return nil, false
+}
+
+// Check the decoded payloads of the content up to the configured depth. The findings
+// are reported at the position of the encoded token and carry the decoding chain.
+func (sr *ScanRepository) checkDecoded(path string, content []byte, depth int) (fileFindings findings) {
+	if depth > sr.DecodeMaxDepth {
+		return
+	}
+	for _, tok := range sr.decodeTokens(content) {
+		// Errors of the decoded payload are ignored as it can be any binary data
+		tokenFindings, _ := sr.checkContent(path, tok.content)
+		tokenFindings = append(tokenFindings, sr.checkDecoded(path, tok.content, depth+1)...)
+		for _, f := range tokenFindings {
+			chain := f.Metadata.DecodingChain
+			if chain == "" {
+				chain = ruleNames[f.RuleID]
+			}
+			f.Metadata.DecodingChain = tok.encoding + " -> " + chain
+			f.Location.Positions = []position{positionAt(content, tok.offset)}
+			fileFindings = append(fileFindings, f)
+		}
+	}
+	return
+}
@@ -0,0 +1,274 @@
+package interfaces
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/scanner/app/domain"
+)
+
+// Revision of the detectors, it is raised when a change of the detectors changes the findings
+// of the same files, so the results of the older detectors are not reused
+const rulesetRevision = 1
+
+var fullHash = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Ruleset is the version of the rules which the scans find the violations with.
+func (sr *ScanRepository) Ruleset() string {
+	valuePattern := ""
+	if sr.ProximityValuePattern != nil {
+		valuePattern = sr.ProximityValuePattern.String()
+	}
+	rules, _ := json.Marshal(struct {
+		Revision              int
+		SearchPattern         []string
+		ScanMode              string
+		DecodeMaxDepth        int
+		DecodeMaxSize         int
+		ProximityKeywords     []string
+		ProximityValuePattern string
+		ProximityMinEntropy   float64
+		ProximityDistance     int
+	}{rulesetRevision, sr.SearchPattern, sr.ScanMode, sr.DecodeMaxDepth, sr.DecodeMaxSize, sr.ProximityKeywords, valuePattern, sr.ProximityMinEntropy, sr.ProximityDistance})
+	sum := sha256.Sum256(rules)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Resolve the commit which the scan of the repo reads, "base..head" for a diff scan. The refs of
+// the remote repos are listed without cloning them. The commit is empty when the scanned files
+// are not those of a commit, as for the worktree of a local source, or when a revision is not a
+// branch, a tag or a full commit hash.
+func (sr *ScanRepository) Resolve(ctx context.Context, repo *domain.Repo, base, head string) (commit string, err error) {
+	strategy := effectiveStrategy(repo.CloneStrategy)
+	if repo.SourceType == "local" {
+		return sr.resolveLocal(repo, strategy.Branch, base, head)
+	}
+
+	auth, err := cloneAuth(repo.Credential)
+	if err != nil {
+		return
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{repo.Url}})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		return
+	}
+	if base == "" && head == "" {
+		if strategy.Branch == "" {
+			return remoteCommit(refs, plumbing.HEAD.String()), nil
+		}
+		return remoteCommit(refs, strategy.Branch), nil
+	}
+	baseCommit, headCommit := remoteCommit(refs, base), remoteCommit(refs, head)
+	if baseCommit == "" || headCommit == "" {
+		return
+	}
+	return baseCommit + ".." + headCommit, nil
+}
+
+// Resolve the commit of the local source, the worktrees are scanned as they are
+func (sr *ScanRepository) resolveLocal(repo *domain.Repo, branch, base, head string) (commit string, err error) {
+	path, err := localSourcePath(repo.Url, sr.LocalRoots)
+	if err != nil {
+		return
+	}
+	gitRepo, openErr := git.PlainOpen(path)
+	if openErr != nil {
+		return
+	}
+	if base != "" || head != "" {
+		baseCommit, err := resolveCommit(gitRepo, base, false)
+		if err != nil {
+			return "", err
+		}
+		headCommit, err := resolveCommit(gitRepo, head, false)
+		if err != nil {
+			return "", err
+		}
+		return baseCommit.Hash.String() + ".." + headCommit.Hash.String(), nil
+	}
+	if _, worktreeErr := gitRepo.Worktree(); worktreeErr != git.ErrIsBareRepository && sr.ScanMode != "objects" {
+		return
+	}
+	hash, err := localCommit(gitRepo, branch)
+	if err != nil {
+		return
+	}
+	return hash.String(), nil
+}
+
+// Commit of the revision in the refs of the remote, empty when it is not found
+func remoteCommit(refs []*plumbing.Reference, revision string) string {
+	if fullHash.MatchString(revision) {
+		return revision
+	}
+	names := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, ref := range refs {
+		names[ref.Name()] = ref
+	}
+	candidates := []plumbing.ReferenceName{
+		plumbing.ReferenceName(revision),
+		plumbing.NewBranchReferenceName(revision),
+		plumbing.NewTagReferenceName(revision),
+	}
+	for _, name := range candidates {
+		// HEAD of the remote is a symbolic ref to its default branch
+		for depth := 0; depth < 5; depth++ {
+			ref, ok := names[name]
+			if !ok {
+				break
+			}
+			if ref.Type() == plumbing.HashReference {
+				return ref.Hash().String()
+			}
+			name = ref.Target()
+		}
+	}
+	return ""
+}
+
+// Key of the scans of the repo which find the same violations, they scan the same commit with
+// the same ruleset, options and clone strategy
+func scanKey(repo *domain.Repo, kind string, scanData *domain.ScanData) string {
+	key, _ := json.Marshal(struct {
+		RepoID   int64
+		Kind     string
+		Commit   string
+		Ruleset  string
+		Options  *domain.ScanOptions
+		Strategy *domain.CloneStrategy
+	}{repo.ID, kind, scanData.Commit, scanData.Ruleset, scanData.Options, effectiveStrategy(repo.CloneStrategy)})
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+// StoreOnce is to create the new entity unless an entity of the same scan key is queued or
+// running, or finished successfully when reuseFinished is set. The existing entity is returned
+// as reused then. The repo row is locked, so the concurrent requests of the repo store one
+// entity.
+func (sr *ScanRepository) StoreOnce(scanData *domain.ScanData, reuseFinished bool) (scanResult *domain.ScanResult, reused bool, err error) {
+	tx, err := sr.SQLHandler.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil || reused {
+			tx.Rollback()
+		}
+	}()
+
+	const lock = `
+		SELECT
+			id
+		FROM
+			repositories
+		WHERE
+			id = ?
+		FOR UPDATE
+	`
+	row, err := tx.Query(lock, scanData.RepoID)
+	if err != nil {
+		return
+	}
+	row.Close()
+
+	const query = `
+		SELECT
+			id,
+			status
+		FROM
+			scan_results
+		WHERE
+			scan_key = ?
+		AND (status IN (1, 2) OR (? AND status = 3))
+		ORDER BY
+			id DESC
+		LIMIT 1
+	`
+	row, err = tx.Query(query, scanData.ScanKey, reuseFinished)
+	if err != nil {
+		return
+	}
+	if row.Next() {
+		var (
+			id     int64
+			status int8
+		)
+		err = row.Scan(&id, &status)
+		row.Close()
+		if err != nil {
+			return
+		}
+		scanResult = &domain.ScanResult{
+			ID:      id,
+			Status:  sr.getStatus(status),
+			Commit:  scanData.Commit,
+			Ruleset: scanData.Ruleset,
+		}
+		reused = true
+		return
+	}
+	row.Close()
+
+	const insert = `
+		INSERT INTO scan_results (
+			repo_id,
+			base_ref,
+			head_ref,
+			options,
+			commit_hash,
+			ruleset,
+			scan_key,
+			status,
+			result,
+			queue_time
+		)
+		VALUES (
+			?,
+			?,
+			?,
+			?,
+			?,
+			?,
+			?,
+			?,
+			?,
+			?
+		)
+	`
+	baseRef := sql.NullString{String: scanData.BaseRef, Valid: scanData.BaseRef != ""}
+	headRef := sql.NullString{String: scanData.HeadRef, Valid: scanData.HeadRef != ""}
+	options, err := encodeOptions(scanData.Options)
+	if err != nil {
+		return
+	}
+	var inserted Result
+	inserted, err = tx.Exec(insert, scanData.RepoID, baseRef, headRef, options, scanData.Commit, scanData.Ruleset, scanData.ScanKey, scanData.Status, scanData.Result, scanData.QueueTime)
+	if err != nil {
+		return
+	}
+	var id int64
+	if id, err = inserted.LastInsertId(); err != nil {
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	scanResult = &domain.ScanResult{
+		ID:      id,
+		Status:  sr.getStatus(scanData.Status),
+		Result:  scanData.Result,
+		Commit:  scanData.Commit,
+		Ruleset: scanData.Ruleset,
+	}
+
+	return
+}
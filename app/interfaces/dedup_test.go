@@ -0,0 +1,94 @@
+package interfaces
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the resolution of the commits which the scans read
+func TestScanResolve(t *testing.T) {
+	source := testGitRepo(t, map[string]string{"keys.go": "var key = \"public_key_1\""})
+	gitRepo, err := git.PlainOpen(source)
+	assert.NoError(t, err)
+	head, err := gitRepo.Head()
+	assert.NoError(t, err)
+	local := &domain.Repo{Url: "file://" + source, SourceType: "local"}
+
+	t.Run("worktree", func(t *testing.T) {
+		// Checkout is scanned with its uncommitted files
+		scanRepository := &ScanRepository{LocalRoots: []string{filepath.Dir(source)}}
+		commit, err := scanRepository.Resolve(context.Background(), local, "", "")
+		assert.NoError(t, err)
+		assert.Empty(t, commit)
+	})
+
+	t.Run("objects", func(t *testing.T) {
+		scanRepository := &ScanRepository{ScanMode: "objects", LocalRoots: []string{filepath.Dir(source)}}
+		commit, err := scanRepository.Resolve(context.Background(), local, "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, head.Hash().String(), commit)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		scanRepository := &ScanRepository{LocalRoots: []string{filepath.Dir(source)}}
+		commit, err := scanRepository.Resolve(context.Background(), local, head.Name().Short(), "HEAD")
+		assert.NoError(t, err)
+		assert.Equal(t, head.Hash().String()+".."+head.Hash().String(), commit)
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		if _, err := exec.LookPath("git-upload-pack"); err != nil {
+			t.Skip("git-upload-pack is required to list local repos")
+		}
+		scanRepository := &ScanRepository{}
+		commit, err := scanRepository.Resolve(context.Background(), &domain.Repo{Url: source}, "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, head.Hash().String(), commit)
+	})
+}
+
+// Test the lookup of the revisions in the refs of a remote
+func TestRemoteCommit(t *testing.T) {
+	main := plumbing.NewHash("1111111111111111111111111111111111111111")
+	tag := plumbing.NewHash("2222222222222222222222222222222222222222")
+	refs := []*plumbing.Reference{
+		plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main")),
+		plumbing.NewHashReference(plumbing.NewBranchReferenceName("main"), main),
+		plumbing.NewHashReference(plumbing.NewTagReferenceName("v1.0"), tag),
+	}
+	assert.Equal(t, main.String(), remoteCommit(refs, "HEAD"))
+	assert.Equal(t, main.String(), remoteCommit(refs, "main"))
+	assert.Equal(t, tag.String(), remoteCommit(refs, "v1.0"))
+	assert.Equal(t, "3333333333333333333333333333333333333333", remoteCommit(refs, "3333333333333333333333333333333333333333"))
+	// Short hashes and relative revisions cannot be resolved without the objects
+	assert.Empty(t, remoteCommit(refs, "1111111"))
+	assert.Empty(t, remoteCommit(refs, "main~1"))
+}
+
+// Test the ruleset version and the keys of the scans
+func TestScanKey(t *testing.T) {
+	scanRepository := &ScanRepository{SearchPattern: []string{"public_key"}}
+	ruleset := scanRepository.Ruleset()
+	assert.Len(t, ruleset, 16)
+	assert.Equal(t, ruleset, (&ScanRepository{SearchPattern: []string{"public_key"}}).Ruleset())
+	assert.NotEqual(t, ruleset, (&ScanRepository{SearchPattern: []string{"public_key", "secret"}}).Ruleset())
+	assert.NotEqual(t, ruleset, (&ScanRepository{SearchPattern: []string{"public_key"}, ProximityValuePattern: regexp.MustCompile(`^sk_`)}).Ruleset())
+
+	repo := &domain.Repo{ID: 1}
+	scanData := &domain.ScanData{Commit: "abc", Ruleset: ruleset}
+	key := scanKey(repo, "scan", scanData)
+	assert.Equal(t, key, scanKey(repo, "scan", &domain.ScanData{Commit: "abc", Ruleset: ruleset}))
+	assert.NotEqual(t, key, scanKey(repo, "diff", scanData))
+	assert.NotEqual(t, key, scanKey(&domain.Repo{ID: 2}, "scan", scanData))
+	assert.NotEqual(t, key, scanKey(repo, "scan", &domain.ScanData{Commit: "abd", Ruleset: ruleset}))
+	assert.NotEqual(t, key, scanKey(repo, "scan", &domain.ScanData{Commit: "abc", Ruleset: ruleset, Options: &domain.ScanOptions{Blame: true}}))
+	assert.NotEqual(t, key, scanKey(&domain.Repo{ID: 1, CloneStrategy: &domain.CloneStrategy{SparsePaths: []string{"src"}}}, "scan", scanData))
+}
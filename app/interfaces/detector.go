@@ -0,0 +1,45 @@
+package interfaces
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A detector inspects the content of a single file and reports the findings in it.
+type detector interface {
+	detect(path string, content []byte) findings
+}
+
+// Short names of the rules used in the decoding chain
+var ruleNames = map[string]string{
+	"G402": "search pattern",
+	"G403": "X.509 certificate",
+	"G404": "JSON Web Token",
+	"G405": "connection string",
+	"G406": "keyword proximity",
+}
+
+// Detectors which are run on every scanned file in addition to the search patterns
+func (sr *ScanRepository) detectors() []detector {
+	detectors := []detector{
+		&certificateDetector{},
+		&jwtDetector{},
+		&connectionDetector{},
+	}
+	if len(sr.ProximityKeywords) > 0 {
+		detectors = append(detectors, &proximityDetector{
+			keywords:     sr.ProximityKeywords,
+			valuePattern: sr.ProximityValuePattern,
+			minEntropy:   sr.ProximityMinEntropy,
+			maxDistance:  sr.ProximityDistance,
+		})
+	}
+	return detectors
+}
+
+// Build the position of the given byte offset in the content
+func positionAt(content []byte, offset int) position {
+	line := bytes.Count(content[:offset], []byte("\n")) + 1
+	col := offset - (bytes.LastIndexByte(content[:offset], '\n') + 1) + 1
+	return position{Begin: begin{Line: fmt.Sprintf("%d", line), Cols: []string{fmt.Sprintf("%d", col)}}}
+}
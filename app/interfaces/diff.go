@@ -0,0 +1,178 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/scanner/app/domain"
+)
+
+var errNoMergeBase = errors.New("no merge base")
+
+// ScanDiff scans the lines which the head revision adds to the base revision. The diff is
+// taken from the merge base, so the changes of the base branch are not part of it. The
+// revisions without a merge base in the history cannot be diffed.
+func (sr *ScanRepository) ScanDiff(ctx context.Context, repo *domain.Repo, base, head string) (scanData *domain.ScanData, err error) {
+	auth, err := cloneAuth(repo.Credential)
+	if err != nil {
+		return
+	}
+	// Both revisions and the history up to their merge base are needed
+	strategy := effectiveStrategy(repo.CloneStrategy)
+	strategy.Depth = 0
+	strategy.SingleBranch = false
+	strategy.Branch = ""
+	strategy.Tags = true
+
+	progressFrom(ctx).setPhase(phaseCloning)
+	gitRepo, _, release, err := sr.openRepository(ctx, repo, strategy, auth)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	// Remote branches of the clones and the mirrors are the up to date ones
+	preferRemote := repo.SourceType != "local"
+	baseCommit, err := resolveCommit(gitRepo, base, preferRemote)
+	if err != nil {
+		return
+	}
+	headCommit, err := resolveCommit(gitRepo, head, preferRemote)
+	if err != nil {
+		return
+	}
+	// Revisions which are scanned, in the form of the resolved commit of the queued scan
+	commit := baseCommit.Hash.String() + ".." + headCommit.Hash.String()
+	mergeBases, err := baseCommit.MergeBase(headCommit)
+	if err != nil {
+		return
+	}
+	if len(mergeBases) == 0 {
+		err = fmt.Errorf("%w of %s and %s", errNoMergeBase, base, head)
+		return
+	}
+	baseCommit = mergeBases[0]
+	patch, err := baseCommit.Patch(headCommit)
+	if err != nil {
+		return
+	}
+	jobs, err := diffJobs(gitRepo, patch, strategy.SparsePaths)
+	if err != nil {
+		return
+	}
+
+	scanData = sr.scanFiles(ctx, func(jobChan chan<- scanJob) error {
+		for _, job := range jobs {
+			jobChan <- job
+		}
+		return nil
+	})
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	scanData.CloneStrategy = strategy
+	scanData.BaseRef = base
+	scanData.HeadRef = head
+	scanData.Commit = commit
+	return
+}
+
+// Resolve the commit of the revision, a branch, tag or commit hash
+func resolveCommit(gitRepo *git.Repository, revision string, preferRemote bool) (*object.Commit, error) {
+	var (
+		hash *plumbing.Hash
+		err  error
+	)
+	if preferRemote {
+		hash, err = gitRepo.ResolveRevision(plumbing.Revision(git.DefaultRemoteName + "/" + revision))
+	}
+	if hash == nil {
+		if hash, err = gitRepo.ResolveRevision(plumbing.Revision(revision)); err != nil {
+			return nil, err
+		}
+	}
+	return gitRepo.CommitObject(*hash)
+}
+
+// Build the jobs of the files which the patch adds or changes. The head side of the file is
+// scanned, so the secrets spanning multiple lines are complete, and only the findings on the
+// added lines are kept. The binary files are scanned whole.
+func diffJobs(gitRepo *git.Repository, patch *object.Patch, sparsePaths []string) (jobs []scanJob, err error) {
+	var storageMu sync.Mutex
+	for _, filePatch := range patch.FilePatches() {
+		_, to := filePatch.Files()
+		// Deleted files add no lines
+		if to == nil || to.Mode() == filemode.Symlink || to.Mode() == filemode.Submodule {
+			continue
+		}
+		if len(sparsePaths) > 0 && !inSparsePaths(to.Path(), sparsePaths) {
+			continue
+		}
+		var lines map[int]bool
+		if !filePatch.IsBinary() {
+			if lines = addedLines(filePatch.Chunks()); len(lines) == 0 {
+				continue
+			}
+		}
+		var blob *object.Blob
+		if blob, err = gitRepo.BlobObject(to.Hash()); err != nil {
+			return
+		}
+		job := blobJob(to.Path(), blob, &storageMu)
+		job.lines = lines
+		job.introduced = true
+		jobs = append(jobs, job)
+	}
+	return
+}
+
+// Map the lines which the chunks add to their line numbers in the new file
+func addedLines(chunks []diff.Chunk) map[int]bool {
+	lines := make(map[int]bool)
+	line := 1
+	for _, chunk := range chunks {
+		content := chunk.Content()
+		count := strings.Count(content, "\n")
+		// Last line of the file can miss the line break
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			count++
+		}
+		switch chunk.Type() {
+		case diff.Equal:
+			line += count
+		case diff.Add:
+			for i := 0; i < count; i++ {
+				lines[line] = true
+				line++
+			}
+		}
+	}
+	return lines
+}
+
+// Keep the positions of the findings which are on the added lines
+func addedFindings(fileFindings findings, lines map[int]bool) (added findings) {
+	for _, f := range fileFindings {
+		var positions []position
+		for _, p := range f.Location.Positions {
+			if line, err := strconv.Atoi(p.Begin.Line); err == nil && lines[line] {
+				positions = append(positions, p)
+			}
+		}
+		if len(positions) == 0 {
+			continue
+		}
+		f.Location.Positions = positions
+		added = append(added, f)
+	}
+	return
+}
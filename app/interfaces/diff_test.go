@@ -0,0 +1,109 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Commit the files into the worktree of the repo
+func testCommit(t *testing.T, directory string, files map[string]string) {
+	gitRepo, err := git.PlainOpen(directory)
+	assert.NoError(t, err)
+	worktree, err := gitRepo.Worktree()
+	assert.NoError(t, err)
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(directory, name), []byte(content), 0644))
+		_, err = worktree.Add(name)
+		assert.NoError(t, err)
+	}
+	_, err = worktree.Commit("change", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+}
+
+// Test the scan of the lines added by a diff
+func TestScanDiff(t *testing.T) {
+	source := testGitRepo(t, map[string]string{"config.go": "package config\nvar old = \"public_key_old\"\n"})
+	gitRepo, err := git.PlainOpen(source)
+	assert.NoError(t, err)
+	head, err := gitRepo.Head()
+	assert.NoError(t, err)
+	worktree, err := gitRepo.Worktree()
+	assert.NoError(t, err)
+
+	// Feature branch adds a secret, the base branch adds another one after the fork
+	assert.NoError(t, worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}))
+	testCommit(t, source, map[string]string{
+		"config.go": "package config\nvar old = \"public_key_old\"\n\nvar added = \"public_key_new\"\n",
+		"new.go":    "package config\n",
+	})
+	assert.NoError(t, worktree.Checkout(&git.CheckoutOptions{Branch: head.Name()}))
+	testCommit(t, source, map[string]string{"base.go": "var base = \"public_key_base\"\n"})
+
+	scanRepository := &ScanRepository{
+		SearchPattern: []string{"public_key"},
+		NoOfWorkers:   2,
+		LocalRoots:    []string{filepath.Dir(source)},
+	}
+	repo := &domain.Repo{Url: source, SourceType: "local"}
+	scanData, err := scanRepository.ScanDiff(context.Background(), repo, head.Name().Short(), "feature")
+	assert.NoError(t, err)
+	assert.Equal(t, int8(3), scanData.Status)
+	assert.Equal(t, "feature", scanData.HeadRef)
+	// Tips of the branches are scanned, not their merge base
+	base, err := gitRepo.Reference(head.Name(), true)
+	assert.NoError(t, err)
+	feature, err := gitRepo.Reference(plumbing.NewBranchReferenceName("feature"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, base.Hash().String()+".."+feature.Hash().String(), scanData.Commit)
+
+	var scanResult result
+	assert.NoError(t, json.Unmarshal([]byte(scanData.Result), &scanResult))
+	if assert.Len(t, scanResult.Findings, 1) {
+		f := scanResult.Findings[0]
+		assert.Equal(t, "config.go", f.Location.Path)
+		assert.Equal(t, []position{{Begin: begin{Line: "4", Cols: []string{"4"}}}}, f.Location.Positions)
+		assert.True(t, f.Metadata.IntroducedInDiff)
+	}
+
+	_, err = scanRepository.ScanDiff(context.Background(), repo, "missing", "feature")
+	assert.Error(t, err)
+
+	// Unrelated history has no merge base to diff from
+	assert.NoError(t, gitRepo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("orphan"))))
+	testCommit(t, source, map[string]string{"orphan.go": "var orphan = \"public_key_orphan\"\n"})
+	_, err = scanRepository.ScanDiff(context.Background(), repo, head.Name().Short(), "orphan")
+	assert.ErrorIs(t, err, errNoMergeBase)
+}
+
+// Test the head side line numbers of the added lines
+func TestAddedLines(t *testing.T) {
+	chunks := []diff.Chunk{
+		testChunk{"a\nb\n", diff.Equal},
+		testChunk{"c\n", diff.Delete},
+		testChunk{"d\ne\n", diff.Add},
+		testChunk{"f\n", diff.Equal},
+		testChunk{"g", diff.Add},
+	}
+	assert.Equal(t, map[int]bool{3: true, 4: true, 6: true}, addedLines(chunks))
+}
+
+type testChunk struct {
+	content   string
+	chunkType diff.Operation
+}
+
+func (c testChunk) Content() string      { return c.content }
+func (c testChunk) Type() diff.Operation { return c.chunkType }
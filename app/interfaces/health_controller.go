@@ -0,0 +1,40 @@
+package interfaces
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/scanner/app/helper"
+)
+
+// A HealthController belong to the interface layer. It reports whether the instance takes new
+// requests, which it stops doing as soon as it starts draining.
+type HealthController struct {
+	draining  chan struct{}
+	drainOnce sync.Once
+}
+
+// NewHealthController returns the health controller of the ready instance.
+func NewHealthController() *HealthController {
+	return &HealthController{draining: make(chan struct{})}
+}
+
+// Drain marks the instance as not ready.
+func (hc *HealthController) Drain() {
+	hc.drainOnce.Do(func() { close(hc.draining) })
+}
+
+// Draining is closed when the instance starts draining.
+func (hc *HealthController) Draining() <-chan struct{} {
+	return hc.draining
+}
+
+// Ready responds 200 while the instance takes new requests, and 503 once it is draining.
+func (hc *HealthController) Ready(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-hc.draining:
+		helper.Write(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+	default:
+		helper.Write(w, http.StatusOK, map[string]string{"status": "ready"})
+	}
+}
@@ -0,0 +1,28 @@
+package interfaces_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scanner/app/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Ready endpoint before and after the drain
+func TestReady(t *testing.T) {
+	healthController := interfaces.NewHealthController()
+	req, err := http.NewRequest("GET", "/api/ready", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	healthController.Ready(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	healthController.Drain()
+	healthController.Drain()
+	rr = httptest.NewRecorder()
+	healthController.Ready(rr, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "draining")
+}
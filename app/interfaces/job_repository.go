@@ -0,0 +1,378 @@
+package interfaces
+
+import (
+	"errors"
+	"time"
+
+	"github.com/scanner/app/domain"
+)
+
+var errLeaseLost = errors.New("lease of the job is held by another worker")
+
+// A JobRepository belong to the inteface layer
+type JobRepository struct {
+	SQLHandler SQLHandler
+	// Running jobs of all the instances, 0 is unlimited
+	MaxScans int
+	// Running jobs of all the instances on each git host, 0 is unlimited
+	MaxScansPerHost int
+}
+
+// Store is to create the new entity.
+func (jr *JobRepository) Store(job *domain.ScanJob) (newJob *domain.ScanJob, err error) {
+	query := `
+		INSERT INTO scan_jobs (
+			result_id,
+			repo_id,
+			kind,
+			priority,
+			team,
+			host
+		)
+		VALUES (
+			?,
+			?,
+			?,
+			?,
+			?,
+			?
+		)
+	`
+	var row Result
+	row, err = jr.SQLHandler.Exec(query, job.ResultID, job.RepoID, job.Kind, job.Priority, job.Team, job.Host)
+	if err != nil {
+		return
+	}
+	var id int64
+	id, err = row.LastInsertId()
+	if err != nil {
+		return
+	}
+	newJob = &domain.ScanJob{
+		ID:       id,
+		ResultID: job.ResultID,
+		RepoID:   job.RepoID,
+		Kind:     job.Kind,
+		Priority: job.Priority,
+		Team:     job.Team,
+		Host:     job.Host,
+	}
+
+	return
+}
+
+// Claim leases the next job which is queued or whose lease has expired. The jobs of the team with
+// the fewest running jobs are claimed first, so the teams take turns, and among the jobs of a team
+// the higher priorities go first, so a team which queues many high priority jobs cannot starve
+// the others. The candidates are ordered without locks, then locked one by one by their id, and
+// the jobs which are locked by the claims of the other instances are skipped, so the instances
+// never wait for each other. The lease times are taken from the database clock, which all the
+// instances share.
+//
+// With the limits of the running jobs, the claims of all the instances are serialized by the
+// lock row, so the running jobs are counted once. No job is claimed when the limit is reached,
+// and the jobs of the git hosts which reached their limit are skipped.
+func (jr *JobRepository) Claim(owner string, lease time.Duration) (job *domain.ScanJob, err error) {
+	tx, err := jr.SQLHandler.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil || job == nil {
+			tx.Rollback()
+		}
+	}()
+
+	if jr.MaxScans > 0 || jr.MaxScansPerHost > 0 {
+		var running int
+		if running, err = jr.lockRunning(tx); err != nil {
+			return
+		}
+		if jr.MaxScans > 0 && running >= jr.MaxScans {
+			return
+		}
+	}
+
+	candidates, err := jr.candidates(tx)
+	if err != nil {
+		return
+	}
+	var claimed *domain.ScanJob
+	for _, id := range candidates {
+		if claimed, err = jr.lockJob(tx, id); err != nil {
+			return
+		}
+		if claimed != nil {
+			break
+		}
+	}
+	if claimed == nil {
+		return
+	}
+
+	const claim = `
+		UPDATE scan_jobs
+		SET
+			lease_owner = ?,
+			lease_expires_time = DATE_ADD(UTC_TIMESTAMP(6), INTERVAL ? MICROSECOND),
+			attempts = attempts + 1
+		WHERE
+			id = ?
+	`
+	if _, err = tx.Exec(claim, owner, lease.Microseconds(), claimed.ID); err != nil {
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	claimed.Attempts++
+	claimed.LeaseOwner = owner
+	claimed.LeaseExpiresTime = time.Now().UTC().Add(lease)
+	job = claimed
+
+	return
+}
+
+// Candidates of a claim taken in one read without locks, so the claims of the other instances
+// can lock some of them in the meantime
+const claimCandidates = 8
+
+// Ids of the claimable jobs in the order of the claims
+func (jr *JobRepository) candidates(tx Tx) (ids []int64, err error) {
+	const query = `
+		SELECT
+			j.id
+		FROM
+			scan_jobs j
+		WHERE
+			(j.lease_expires_time IS NULL OR j.lease_expires_time < UTC_TIMESTAMP(6))
+		AND (
+			? = 0
+			OR j.host = ''
+			OR (
+				SELECT COUNT(*)
+				FROM scan_jobs h
+				WHERE
+					h.host = j.host
+				AND h.lease_owner IS NOT NULL
+				AND h.lease_expires_time >= UTC_TIMESTAMP(6)
+			) < ?
+		)
+		ORDER BY
+			(
+				SELECT COUNT(*)
+				FROM scan_jobs t
+				WHERE
+					t.team = j.team
+				AND t.lease_owner IS NOT NULL
+				AND t.lease_expires_time >= UTC_TIMESTAMP(6)
+			),
+			j.priority DESC,
+			j.id
+		LIMIT ?
+	`
+	rows, err := tx.Query(query, jr.MaxScansPerHost, jr.MaxScansPerHost, claimCandidates)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	return
+}
+
+// Lock the job by its id, nil when another claim holds its lock or has claimed it since it was read
+func (jr *JobRepository) lockJob(tx Tx, id int64) (job *domain.ScanJob, err error) {
+	const query = `
+		SELECT
+			id,
+			result_id,
+			repo_id,
+			kind,
+			attempts,
+			retries,
+			priority,
+			team,
+			host
+		FROM
+			scan_jobs
+		WHERE
+			id = ?
+		AND (lease_expires_time IS NULL OR lease_expires_time < UTC_TIMESTAMP(6))
+		FOR UPDATE SKIP LOCKED
+	`
+	row, err := tx.Query(query, id)
+	if err != nil {
+		return
+	}
+	defer row.Close()
+	if row.Next() {
+		locked := &domain.ScanJob{}
+		if err = row.Scan(&locked.ID, &locked.ResultID, &locked.RepoID, &locked.Kind, &locked.Attempts, &locked.Retries, &locked.Priority, &locked.Team, &locked.Host); err != nil {
+			return
+		}
+		job = locked
+	}
+
+	return
+}
+
+// Take the lock row of the claims until the transaction ends, and count the running jobs of all
+// the instances
+func (jr *JobRepository) lockRunning(tx Tx) (running int, err error) {
+	const lock = `
+		INSERT INTO scan_queue_lock (id)
+		VALUES (1)
+		ON DUPLICATE KEY UPDATE id = id
+	`
+	if _, err = tx.Exec(lock); err != nil {
+		return
+	}
+	const query = `
+		SELECT
+			COUNT(*)
+		FROM
+			scan_jobs
+		WHERE
+			lease_owner IS NOT NULL
+		AND lease_expires_time >= UTC_TIMESTAMP(6)
+	`
+	row, err := tx.Query(query)
+	if err != nil {
+		return
+	}
+	defer row.Close()
+	if row.Next() {
+		err = row.Scan(&running)
+	}
+
+	return
+}
+
+// Renew is the heartbeat of the claimed job, which fails when the lease has been taken over.
+func (jr *JobRepository) Renew(job *domain.ScanJob, lease time.Duration) (err error) {
+	query := `
+		UPDATE scan_jobs
+		SET
+			lease_expires_time = DATE_ADD(UTC_TIMESTAMP(6), INTERVAL ? MICROSECOND)
+		WHERE
+			id = ?
+		AND lease_owner = ?
+	`
+	var row Result
+	row, err = jr.SQLHandler.Exec(query, lease.Microseconds(), job.ID, job.LeaseOwner)
+	if err != nil {
+		return
+	}
+	affected, err := row.RowsAffected()
+	if err != nil {
+		return
+	}
+	if affected == 0 {
+		return errLeaseLost
+	}
+	job.LeaseExpiresTime = time.Now().UTC().Add(lease)
+
+	return
+}
+
+// Complete is to delete the finished entity of the lease owner.
+func (jr *JobRepository) Complete(job *domain.ScanJob) (err error) {
+	query := `
+		DELETE FROM scan_jobs
+		WHERE
+			id = ?
+		AND lease_owner = ?
+	`
+	var row Result
+	row, err = jr.SQLHandler.Exec(query, job.ID, job.LeaseOwner)
+	if err != nil {
+		return
+	}
+	affected, err := row.RowsAffected()
+	if err != nil {
+		return
+	}
+	if affected == 0 {
+		return errLeaseLost
+	}
+
+	return
+}
+
+// Retry releases the lease of the failed job until the delay has passed. The expiry of the
+// released lease is the time of the retry, the attempts of the retry are counted from 0 again.
+func (jr *JobRepository) Retry(job *domain.ScanJob, delay time.Duration) (err error) {
+	query := `
+		UPDATE scan_jobs
+		SET
+			lease_owner = NULL,
+			lease_expires_time = DATE_ADD(UTC_TIMESTAMP(6), INTERVAL ? MICROSECOND),
+			attempts = 0,
+			retries = retries + 1
+		WHERE
+			id = ?
+		AND lease_owner = ?
+	`
+	var row Result
+	row, err = jr.SQLHandler.Exec(query, delay.Microseconds(), job.ID, job.LeaseOwner)
+	if err != nil {
+		return
+	}
+	affected, err := row.RowsAffected()
+	if err != nil {
+		return
+	}
+	if affected == 0 {
+		return errLeaseLost
+	}
+
+	return
+}
+
+// Release is to give up the lease of the claimed entity, so any worker claims it again at once.
+// The claim is not counted as an attempt.
+func (jr *JobRepository) Release(job *domain.ScanJob) (err error) {
+	query := `
+		UPDATE scan_jobs
+		SET
+			lease_owner = NULL,
+			lease_expires_time = NULL,
+			attempts = attempts - 1
+		WHERE
+			id = ?
+		AND lease_owner = ?
+	`
+	var row Result
+	row, err = jr.SQLHandler.Exec(query, job.ID, job.LeaseOwner)
+	if err != nil {
+		return
+	}
+	affected, err := row.RowsAffected()
+	if err != nil {
+		return
+	}
+	if affected == 0 {
+		return errLeaseLost
+	}
+
+	return
+}
+
+// Remove is to delete the entities of the scan result, whether they are claimed or not.
+func (jr *JobRepository) Remove(resultID int64) (err error) {
+	query := `
+		DELETE FROM scan_jobs
+		WHERE
+			result_id = ?
+	`
+	_, err = jr.SQLHandler.Exec(query, resultID)
+
+	return
+}
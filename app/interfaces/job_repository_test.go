@@ -0,0 +1,177 @@
+package interfaces_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scanner/app/domain"
+	"github.com/scanner/app/domain/mocks"
+	"github.com/scanner/app/interfaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Match the statements which contain the fragment
+func statement(fragment string) interface{} {
+	return mock.MatchedBy(func(query string) bool { return strings.Contains(query, fragment) })
+}
+
+// Rows of a query which are closed after they are read
+func mockRows(rows ...[]interface{}) *mocks.Row {
+	row := &mocks.Row{Rows: rows}
+	row.On("Close").Return(nil).Once()
+	return row
+}
+
+// Result of a statement which changed the rows
+func mockAffected(affected int64) *mocks.Result {
+	result := new(mocks.Result)
+	result.On("RowsAffected").Return(affected, nil).Once()
+	return result
+}
+
+// Test the claim of the jobs with the locking read
+func TestJobClaim(t *testing.T) {
+	t.Run("claimed", func(t *testing.T) {
+		tx := new(mocks.Tx)
+		mockSQLHandler := new(mocks.SQLHandler)
+		mockSQLHandler.On("Begin").Return(tx, nil).Once()
+		// Teams take turns before the priorities of their jobs are compared
+		fairness := mock.MatchedBy(func(query string) bool {
+			team := strings.Index(query, "t.team = j.team")
+			return team >= 0 && team < strings.Index(query, "j.priority DESC") && !strings.Contains(query, "FOR UPDATE")
+		})
+		candidates := mockRows([]interface{}{int64(3)})
+		tx.On("Query", fairness, []interface{}{0, 0, 8}).Return(candidates, nil).Once()
+		rows := mockRows([]interface{}{int64(3), int64(7), int64(1), "scan", 1, 2, 2, "payments", "github.com"})
+		tx.On("Query", statement("FOR UPDATE SKIP LOCKED"), []interface{}{int64(3)}).Return(rows, nil).Once()
+		tx.On("Exec", statement("attempts = attempts + 1"), []interface{}{"worker-1", time.Minute.Microseconds(), int64(3)}).Return(nil, nil).Once()
+		tx.On("Commit").Return(nil).Once()
+
+		job, err := (&interfaces.JobRepository{SQLHandler: mockSQLHandler}).Claim("worker-1", time.Minute)
+		assert.NoError(t, err)
+		if assert.NotNil(t, job) {
+			assert.Equal(t, int64(7), job.ResultID)
+			assert.Equal(t, 2, job.Attempts)
+			assert.Equal(t, 2, job.Retries)
+			assert.Equal(t, 2, job.Priority)
+			assert.Equal(t, "payments", job.Team)
+			assert.Equal(t, "github.com", job.Host)
+			assert.Equal(t, "worker-1", job.LeaseOwner)
+		}
+		tx.AssertExpectations(t)
+		candidates.AssertExpectations(t)
+		rows.AssertExpectations(t)
+		tx.AssertNotCalled(t, "Rollback")
+	})
+
+	t.Run("taken", func(t *testing.T) {
+		tx := new(mocks.Tx)
+		mockSQLHandler := new(mocks.SQLHandler)
+		mockSQLHandler.On("Begin").Return(tx, nil).Once()
+		tx.On("Query", statement("LIMIT ?"), mock.Anything).Return(mockRows([]interface{}{int64(3)}, []interface{}{int64(4)}), nil).Once()
+		// First candidate was locked by the claim of another instance after it was read
+		tx.On("Query", statement("FOR UPDATE SKIP LOCKED"), []interface{}{int64(3)}).Return(mockRows(), nil).Once()
+		tx.On("Query", statement("FOR UPDATE SKIP LOCKED"), []interface{}{int64(4)}).Return(mockRows([]interface{}{int64(4), int64(8), int64(1), "scan", 0, 0, 1, "", ""}), nil).Once()
+		tx.On("Exec", statement("attempts = attempts + 1"), []interface{}{"worker-1", time.Minute.Microseconds(), int64(4)}).Return(nil, nil).Once()
+		tx.On("Commit").Return(nil).Once()
+
+		job, err := (&interfaces.JobRepository{SQLHandler: mockSQLHandler}).Claim("worker-1", time.Minute)
+		assert.NoError(t, err)
+		if assert.NotNil(t, job) {
+			assert.Equal(t, int64(4), job.ID)
+			assert.Equal(t, int64(8), job.ResultID)
+		}
+		tx.AssertExpectations(t)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		tx := new(mocks.Tx)
+		mockSQLHandler := new(mocks.SQLHandler)
+		mockSQLHandler.On("Begin").Return(tx, nil).Once()
+		tx.On("Query", statement("LIMIT ?"), mock.Anything).Return(mockRows(), nil).Once()
+		tx.On("Rollback").Return(nil).Once()
+
+		job, err := (&interfaces.JobRepository{SQLHandler: mockSQLHandler}).Claim("worker-1", time.Minute)
+		assert.NoError(t, err)
+		assert.Nil(t, job)
+		tx.AssertExpectations(t)
+		tx.AssertNotCalled(t, "Exec", mock.Anything, mock.Anything)
+	})
+
+	t.Run("limit reached", func(t *testing.T) {
+		tx := new(mocks.Tx)
+		mockSQLHandler := new(mocks.SQLHandler)
+		mockSQLHandler.On("Begin").Return(tx, nil).Once()
+		tx.On("Exec", statement("scan_queue_lock"), mock.Anything).Return(nil, nil).Once()
+		tx.On("Query", statement("COUNT(*)"), mock.Anything).Return(mockRows([]interface{}{2}), nil).Once()
+		tx.On("Rollback").Return(nil).Once()
+
+		job, err := (&interfaces.JobRepository{SQLHandler: mockSQLHandler, MaxScans: 2}).Claim("worker-1", time.Minute)
+		assert.NoError(t, err)
+		assert.Nil(t, job)
+		tx.AssertExpectations(t)
+	})
+
+	t.Run("host limit", func(t *testing.T) {
+		tx := new(mocks.Tx)
+		mockSQLHandler := new(mocks.SQLHandler)
+		mockSQLHandler.On("Begin").Return(tx, nil).Once()
+		// Lock row is taken before the running jobs are counted
+		tx.On("Exec", statement("scan_queue_lock"), mock.Anything).Return(nil, nil).Once()
+		tx.On("Query", statement("lease_owner IS NOT NULL"), []interface{}(nil)).Return(mockRows([]interface{}{0}), nil).Once()
+		tx.On("Query", statement("h.host = j.host"), []interface{}{1, 1, 8}).Return(mockRows(), nil).Once()
+		tx.On("Rollback").Return(nil).Once()
+
+		job, err := (&interfaces.JobRepository{SQLHandler: mockSQLHandler, MaxScansPerHost: 1}).Claim("worker-1", time.Minute)
+		assert.NoError(t, err)
+		assert.Nil(t, job)
+		tx.AssertExpectations(t)
+	})
+}
+
+// Test the heartbeat of a lease which was taken over
+func TestJobRenew(t *testing.T) {
+	job := &domain.ScanJob{ID: 3, LeaseOwner: "worker-1"}
+	mockSQLHandler := new(mocks.SQLHandler)
+	mockSQLHandler.On("Exec", statement("lease_expires_time = DATE_ADD"), []interface{}{time.Minute.Microseconds(), int64(3), "worker-1"}).Return(mockAffected(1), nil).Once()
+	mockSQLHandler.On("Exec", statement("lease_expires_time = DATE_ADD"), mock.Anything).Return(mockAffected(0), nil).Once()
+	mockSQLHandler.On("Exec", statement("DELETE FROM scan_jobs"), []interface{}{int64(3), "worker-1"}).Return(mockAffected(0), nil).Once()
+
+	jobRepository := &interfaces.JobRepository{SQLHandler: mockSQLHandler}
+	assert.NoError(t, jobRepository.Renew(job, time.Minute))
+	assert.EqualError(t, jobRepository.Renew(job, time.Minute), "lease of the job is held by another worker")
+	assert.EqualError(t, jobRepository.Complete(job), "lease of the job is held by another worker")
+	mockSQLHandler.AssertExpectations(t)
+}
+
+// Test the release of the failed job until its retry
+func TestJobRetry(t *testing.T) {
+	job := &domain.ScanJob{ID: 3, LeaseOwner: "worker-1"}
+	mockSQLHandler := new(mocks.SQLHandler)
+	retry := statement("retries = retries + 1")
+	mockSQLHandler.On("Exec", retry, []interface{}{time.Minute.Microseconds(), int64(3), "worker-1"}).Return(mockAffected(1), nil).Once()
+	mockSQLHandler.On("Exec", retry, mock.Anything).Return(mockAffected(0), nil).Once()
+
+	jobRepository := &interfaces.JobRepository{SQLHandler: mockSQLHandler}
+	assert.NoError(t, jobRepository.Retry(job, time.Minute))
+	assert.EqualError(t, jobRepository.Retry(job, time.Minute), "lease of the job is held by another worker")
+	mockSQLHandler.AssertExpectations(t)
+	mockSQLHandler.AssertCalled(t, "Exec", statement("lease_owner = NULL"), mock.Anything)
+}
+
+// Test the release of the job which is claimed again without an attempt
+func TestJobRelease(t *testing.T) {
+	job := &domain.ScanJob{ID: 3, LeaseOwner: "worker-1"}
+	mockSQLHandler := new(mocks.SQLHandler)
+	release := statement("attempts = attempts - 1")
+	mockSQLHandler.On("Exec", release, []interface{}{int64(3), "worker-1"}).Return(mockAffected(1), nil).Once()
+	mockSQLHandler.On("Exec", release, mock.Anything).Return(mockAffected(0), nil).Once()
+
+	jobRepository := &interfaces.JobRepository{SQLHandler: mockSQLHandler}
+	assert.NoError(t, jobRepository.Release(job))
+	assert.EqualError(t, jobRepository.Release(job), "lease of the job is held by another worker")
+	mockSQLHandler.AssertExpectations(t)
+	mockSQLHandler.AssertCalled(t, "Exec", statement("lease_expires_time = NULL"), mock.Anything)
+}
@@ -0,0 +1,79 @@
+package interfaces
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Struct for the decoded JWT details of a finding
+type token struct {
+	Alg     string `json:"alg"`
+	Iss     string `json:"iss,omitempty"`
+	Sub     string `json:"sub,omitempty"`
+	Exp     string `json:"exp,omitempty"`
+	Expired bool   `json:"expired"`
+}
+
+// Header and claims are JSON objects, so both segments start with the encoded `{"`
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// A jwtDetector reports the JSON Web Tokens. The tokens are decoded but not verified.
+type jwtDetector struct {
+	now func() time.Time
+}
+
+func (jd *jwtDetector) detect(path string, content []byte) (fileFindings findings) {
+	now := time.Now().UTC()
+	if jd.now != nil {
+		now = jd.now()
+	}
+	parser := &jwt.Parser{}
+	for _, loc := range jwtPattern.FindAllIndex(content, -1) {
+		claims := jwt.MapClaims{}
+		parsed, _, err := parser.ParseUnverified(string(content[loc[0]:loc[1]]), claims)
+		// Unknown signing methods are still tokens, only malformed ones are skipped
+		if err != nil {
+			if vErr, ok := err.(*jwt.ValidationError); !ok || vErr.Errors&jwt.ValidationErrorMalformed != 0 {
+				continue
+			}
+		}
+		details := &token{}
+		details.Alg, _ = parsed.Header["alg"].(string)
+		details.Iss, _ = claims["iss"].(string)
+		details.Sub, _ = claims["sub"].(string)
+
+		md := metadata{
+			Description: "JSON Web Token is present",
+			Severity:    "HIGH",
+			Token:       details,
+		}
+		if exp, ok := claims["exp"].(float64); ok {
+			expTime := time.Unix(int64(exp), 0).UTC()
+			details.Exp = expTime.Format(time.RFC3339)
+			details.Expired = now.After(expTime)
+		}
+		switch {
+		case strings.EqualFold(details.Alg, "none"):
+			md.Description = "Unsigned JSON Web Token is present"
+			md.Severity = "LOW"
+		case details.Expired:
+			md.Description = "Expired JSON Web Token is present"
+			md.Severity = "MEDIUM"
+		case details.Exp == "":
+			md.Description = "JSON Web Token without expiry is present"
+		}
+		fileFindings = append(fileFindings, finding{
+			ErrorType: "sast",
+			RuleID:    "G404",
+			Location: location{
+				Path:      path,
+				Positions: []position{positionAt(content, loc[0])},
+			},
+			Metadata: md,
+		})
+	}
+	return
+}
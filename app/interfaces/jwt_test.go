@@ -0,0 +1,60 @@
+package interfaces
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test JWT detection
+func TestJWTDetector(t *testing.T) {
+	now := time.Now().UTC()
+	jd := &jwtDetector{now: func() time.Time { return now }}
+
+	t.Run("unexpired", func(t *testing.T) {
+		raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss": "auth.example.com",
+			"sub": "1234",
+			"exp": now.Add(time.Hour).Unix(),
+		}).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		results := jd.detect("test.env", []byte("TOKEN="+raw+"\n"))
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "G404", results[0].RuleID)
+		assert.Equal(t, []string{"7"}, results[0].Location.Positions[0].Begin.Cols)
+		assert.Equal(t, "HS256", results[0].Metadata.Token.Alg)
+		assert.Equal(t, "auth.example.com", results[0].Metadata.Token.Iss)
+		assert.Equal(t, "1234", results[0].Metadata.Token.Sub)
+		assert.False(t, results[0].Metadata.Token.Expired)
+		assert.Equal(t, "HIGH", results[0].Metadata.Severity)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"exp": now.Add(-time.Hour).Unix(),
+		}).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		results := jd.detect("test.env", []byte(raw))
+		assert.Equal(t, 1, len(results))
+		assert.True(t, results[0].Metadata.Token.Expired)
+		assert.Equal(t, "MEDIUM", results[0].Metadata.Severity)
+	})
+
+	t.Run("alg-none", func(t *testing.T) {
+		raw, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+			"sub": "test",
+		}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		assert.NoError(t, err)
+		results := jd.detect("test.env", []byte(raw))
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "none", results[0].Metadata.Token.Alg)
+		assert.Equal(t, "LOW", results[0].Metadata.Severity)
+	})
+
+	t.Run("notfound", func(t *testing.T) {
+		results := jd.detect("test.env", []byte("eyJub3Q.eyJqc29u.abc"))
+		assert.Equal(t, 0, len(results))
+	})
+}
@@ -0,0 +1,70 @@
+package interfaces
+
+import (
+	"context"
+	"fmt"
+)
+
+// Priorities of the scan requests, the queued jobs of a higher priority are claimed first within
+// the turn of their team
+var scanPriorities = map[string]int{"low": 0, "normal": 1, "high": 2}
+
+// Priority of the scan request, normal when it is not given
+func scanPriority(value string) (int, error) {
+	if value == "" {
+		return scanPriorities["normal"], nil
+	}
+	priority, ok := scanPriorities[value]
+	if !ok {
+		return 0, fmt.Errorf("priority must be low, normal or high")
+	}
+	return priority, nil
+}
+
+// A WorkerPool limits the file workers of all the scans of the instance. Each scan has one worker
+// which waits for a free slot, and its other workers only start while slots are free, so every
+// running scan makes progress. A nil pool is unlimited.
+type WorkerPool struct {
+	slots chan struct{}
+}
+
+// NewWorkerPool returns the pool of the given slots, nil when there is no limit.
+func NewWorkerPool(size int) *WorkerPool {
+	if size <= 0 {
+		return nil
+	}
+	return &WorkerPool{slots: make(chan struct{}, size)}
+}
+
+// Wait for a free slot, false when the context is done first
+func (wp *WorkerPool) acquire(ctx context.Context) bool {
+	if wp == nil {
+		return true
+	}
+	select {
+	case wp.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Take a free slot without waiting
+func (wp *WorkerPool) tryAcquire() bool {
+	if wp == nil {
+		return true
+	}
+	select {
+	case wp.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Free the slot of the finished worker
+func (wp *WorkerPool) release() {
+	if wp != nil {
+		<-wp.slots
+	}
+}
@@ -0,0 +1,94 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the priorities of the scan requests
+func TestScanPriority(t *testing.T) {
+	for value, expected := range map[string]int{"": 1, "low": 0, "normal": 1, "high": 2} {
+		priority, err := scanPriority(value)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, priority, value)
+	}
+	_, err := scanPriority("urgent")
+	assert.Error(t, err)
+}
+
+// Test the slots of the file workers of the scans
+func TestWorkerPool(t *testing.T) {
+	pool := NewWorkerPool(2)
+	assert.True(t, pool.acquire(context.Background()))
+	assert.True(t, pool.tryAcquire())
+	assert.False(t, pool.tryAcquire())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, pool.acquire(ctx))
+	pool.release()
+	assert.True(t, pool.tryAcquire())
+
+	// Without a limit every worker starts
+	assert.Nil(t, NewWorkerPool(0))
+	assert.True(t, (*WorkerPool)(nil).tryAcquire())
+}
+
+// Test the scans which share the slots of the file workers
+func TestScanFileWorkers(t *testing.T) {
+	source := testGitRepo(t, map[string]string{"a.go": "public_key_1", "b.go": "public_key_2", "c.go": "public_key_3"})
+	scanRepository := &ScanRepository{
+		SearchPattern: []string{"public_key"},
+		NoOfWorkers:   4,
+		FileWorkers:   NewWorkerPool(1),
+		LocalRoots:    []string{filepath.Dir(source)},
+	}
+	// Slot is taken by another scan until it finishes
+	assert.True(t, scanRepository.FileWorkers.tryAcquire())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		scanRepository.FileWorkers.release()
+	}()
+
+	scanData, err := scanRepository.Scan(context.Background(), &domain.Repo{Url: "file://" + source, SourceType: "local"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int8(3), scanData.Status)
+	assert.Len(t, scanRepository.FileWorkers.slots, 0)
+}
+
+// Test the scan which fails to read a file, the other workers finish and release their slots
+func TestScanFilesReadError(t *testing.T) {
+	scanRepository := &ScanRepository{
+		SearchPattern: []string{"public_key"},
+		NoOfWorkers:   2,
+		FileWorkers:   NewWorkerPool(2),
+	}
+	scanned := make(chan *domain.ScanData, 1)
+	go func() {
+		scanned <- scanRepository.scanFiles(context.Background(), func(jobs chan<- scanJob) error {
+			jobs <- scanJob{path: "unreadable.go", read: func() ([]byte, error) {
+				return nil, errors.New("permission denied")
+			}}
+			for i := 0; i < 50; i++ {
+				jobs <- scanJob{path: fmt.Sprintf("file%d.go", i), read: func() ([]byte, error) {
+					return []byte("public_key"), nil
+				}}
+			}
+			return nil
+		})
+	}()
+	select {
+	case scanData := <-scanned:
+		assert.Equal(t, int8(4), scanData.Status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan did not finish after the read error")
+	}
+	assert.Len(t, scanRepository.FileWorkers.slots, 0)
+}
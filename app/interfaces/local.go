@@ -0,0 +1,120 @@
+package interfaces
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/scanner/app/domain"
+)
+
+var errLocalSourceDenied = errors.New("path is not inside the allowed local roots")
+
+// Load the root directories of the local sources from the comma separated LOCAL_SOURCE_ROOTS.
+// The roots which do not exist are dropped.
+func loadLocalRoots() (roots []string) {
+	for _, root := range splitList(os.Getenv("LOCAL_SOURCE_ROOTS")) {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if root, err = filepath.EvalSymlinks(root); err == nil {
+			roots = append(roots, root)
+		}
+	}
+	return
+}
+
+// Resolve the directory of the local source, an absolute path or a file:// URL. The symlinks
+// are resolved before checking that the directory is inside any of the allowed roots.
+func localSourcePath(sourceUrl string, roots []string) (path string, err error) {
+	path = sourceUrl
+	if strings.HasPrefix(sourceUrl, "file://") {
+		var u *url.URL
+		u, err = url.Parse(sourceUrl)
+		if err != nil {
+			return
+		}
+		if u.Host != "" && u.Host != "localhost" {
+			return "", errors.New("file URL must be on the local host")
+		}
+		path = u.Path
+	}
+	if !filepath.IsAbs(path) {
+		return "", errors.New("must be an absolute path or a file URL")
+	}
+	path, err = filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return
+	}
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return path, nil
+		}
+	}
+	return "", errLocalSourceDenied
+}
+
+// Validation rule of a local source inside the allowed roots
+func validLocalSource(roots []string) func(value interface{}) error {
+	return func(value interface{}) error {
+		sourceUrl, _ := value.(string)
+		_, err := localSourcePath(sourceUrl, roots)
+		return err
+	}
+}
+
+// Resolve the files of the local source without any network access. The bare repos, and the
+// git repos in the objects scan mode, are scanned from the commit of the branch. The other
+// directories are scanned in place.
+func (sr *ScanRepository) localWalk(repo *domain.Repo, strategy *domain.CloneStrategy) (walk func(jobs chan<- scanJob) error, commit string, err error) {
+	path, err := localSourcePath(repo.Url, sr.LocalRoots)
+	if err != nil {
+		return
+	}
+	if gitRepo, openErr := git.PlainOpen(path); openErr == nil {
+		_, worktreeErr := gitRepo.Worktree()
+		if worktreeErr == git.ErrIsBareRepository || sr.ScanMode == "objects" {
+			var hash plumbing.Hash
+			if hash, err = localCommit(gitRepo, strategy.Branch); err != nil {
+				return
+			}
+			var tree *object.Tree
+			if tree, err = commitTree(gitRepo, hash); err != nil {
+				return
+			}
+			walk = func(jobs chan<- scanJob) error {
+				return walkObjects(tree, strategy.SparsePaths, jobs)
+			}
+			commit = hash.String()
+			return
+		}
+	}
+	walk = func(jobs chan<- scanJob) error {
+		return walkWorktree(path, strategy.SparsePaths, jobs)
+	}
+	return
+}
+
+// Resolve the commit of the branch, HEAD when the branch is not given
+func localCommit(gitRepo *git.Repository, branch string) (plumbing.Hash, error) {
+	var (
+		ref *plumbing.Reference
+		err error
+	)
+	if branch == "" {
+		ref, err = gitRepo.Head()
+	} else {
+		ref, err = gitRepo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return ref.Hash(), nil
+}
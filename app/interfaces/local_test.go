@@ -0,0 +1,78 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the allowlist of the local sources
+func TestLocalSourcePath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "checkout"), 0755))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+	roots := []string{root}
+
+	path, err := localSourcePath(filepath.Join(root, "checkout"), roots)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "checkout"), path)
+
+	_, err = localSourcePath("file://"+filepath.Join(root, "checkout"), roots)
+	assert.NoError(t, err)
+
+	_, err = localSourcePath(outside, roots)
+	assert.Equal(t, errLocalSourceDenied, err)
+
+	_, err = localSourcePath(filepath.Join(root, "escape"), roots)
+	assert.Equal(t, errLocalSourceDenied, err)
+
+	_, err = localSourcePath(filepath.Join(root, "..", filepath.Base(outside)), roots)
+	assert.Equal(t, errLocalSourceDenied, err)
+
+	_, err = localSourcePath("checkout", roots)
+	assert.Error(t, err)
+
+	_, err = localSourcePath("file://remote.example.com"+root, roots)
+	assert.Error(t, err)
+}
+
+// Test the scan of the local sources
+func TestScanLocal(t *testing.T) {
+	source := testGitRepo(t, map[string]string{"keys.go": "var key = \"public_key_1\""})
+	// Uncommitted files of the checkout are scanned in place
+	assert.NoError(t, os.WriteFile(filepath.Join(source, "local.go"), []byte("public_key_2"), 0644))
+
+	for mode, expected := range map[string][]string{"worktree": {"keys.go", "local.go"}, "objects": {"keys.go"}} {
+		t.Run(mode, func(t *testing.T) {
+			scanRepository := &ScanRepository{
+				SearchPattern: []string{"public_key"},
+				NoOfWorkers:   2,
+				ScanMode:      mode,
+				LocalRoots:    []string{filepath.Dir(source)},
+			}
+			scanData, err := scanRepository.Scan(context.Background(), &domain.Repo{Url: "file://" + source, SourceType: "local"}, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, int8(3), scanData.Status)
+
+			var scanResult result
+			assert.NoError(t, json.Unmarshal([]byte(scanData.Result), &scanResult))
+			var paths []string
+			for _, f := range scanResult.Findings {
+				paths = append(paths, filepath.Base(f.Location.Path))
+			}
+			assert.ElementsMatch(t, expected, paths)
+		})
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		scanRepository := &ScanRepository{NoOfWorkers: 1, LocalRoots: []string{t.TempDir()}}
+		_, err := scanRepository.Scan(context.Background(), &domain.Repo{Url: source, SourceType: "local"}, nil)
+		assert.Equal(t, errLocalSourceDenied, err)
+	})
+}
@@ -0,0 +1,378 @@
+package interfaces
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/scanner/app/domain"
+)
+
+var errMirrorCorrupt = errors.New("mirror is corrupt")
+
+// A MirrorCache keeps a bare mirror of each repo and history, so the later scans of the repo
+// only fetch the new objects. The worktree of each scan is checked out from the mirror.
+type MirrorCache struct {
+	Folder string
+	// Maximum total size in bytes of the mirrors, 0 disables the eviction
+	MaxSize int64
+
+	mu    sync.Mutex
+	locks map[string]*mirrorLock
+}
+
+// A mirrorLock serializes the scans of the same mirror
+type mirrorLock struct {
+	sync.Mutex
+	// Scans which hold or wait for the lock
+	users int
+}
+
+// NewMirrorCache returns the mirror cache of the folder.
+func NewMirrorCache(folder string, maxSize int64) (*MirrorCache, error) {
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return nil, err
+	}
+	return &MirrorCache{
+		Folder:  folder,
+		MaxSize: maxSize,
+		locks:   make(map[string]*mirrorLock),
+	}, nil
+}
+
+// Update the mirror of the repo and lock it until the returned function releases it.
+// The hash is the commit to scan.
+func (mc *MirrorCache) open(ctx context.Context, repo *domain.Repo, strategy *domain.CloneStrategy, auth transport.AuthMethod) (mirror *git.Repository, hash plumbing.Hash, release func(), err error) {
+	key := mirrorKey(repo.Url, strategy)
+	mirrorDir := filepath.Join(mc.Folder, key)
+	unlock := mc.lock(key)
+	release = func() {
+		unlock()
+		mc.evict()
+	}
+
+	mirror, hash, err = mc.update(ctx, mirrorDir, repo, strategy, auth)
+	// Corrupt mirror is cloned again
+	if err == errMirrorCorrupt {
+		if err = os.RemoveAll(mirrorDir); err == nil {
+			mirror, hash, err = mc.update(ctx, mirrorDir, repo, strategy, auth)
+		}
+	}
+	if err != nil {
+		release()
+		return
+	}
+	// Modification time is the last use of the mirror for the eviction
+	now := time.Now()
+	os.Chtimes(mirrorDir, now, now)
+	return
+}
+
+// Check out the commit to scan into the directory from the mirror of the repo. The returned
+// repo reads its objects from the mirror, which stays locked until the function releases it.
+// The refs, index and submodules of the checkout are stored in the directory, so the checkout
+// never changes the mirror.
+func (mc *MirrorCache) checkout(ctx context.Context, directory string, repo *domain.Repo, strategy *domain.CloneStrategy, auth transport.AuthMethod) (worktreeRepo *git.Repository, release func(), err error) {
+	mirror, hash, release, err := mc.open(ctx, repo, strategy, auth)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	checkoutStorage := &checkoutStorage{
+		Storage: filesystem.NewStorage(osfs.New(filepath.Join(directory, git.GitDirName)), cache.NewObjectLRUDefault()),
+		mirror:  mirror.Storer,
+	}
+	// Detached HEAD is reset without moving a branch
+	if err = checkoutStorage.SetReference(plumbing.NewHashReference(plumbing.HEAD, hash)); err != nil {
+		return
+	}
+	if worktreeRepo, err = git.Open(checkoutStorage, osfs.New(directory)); err != nil {
+		return
+	}
+	worktree, err := worktreeRepo.Worktree()
+	if err != nil {
+		return
+	}
+	if len(strategy.SparsePaths) > 0 {
+		err = checkoutSparse(worktreeRepo, worktree, hash, strategy.SparsePaths)
+	} else {
+		err = worktree.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset})
+	}
+	if err != nil {
+		return
+	}
+	err = updateSubmodules(ctx, worktree, repo.Url, strategy, auth, 1)
+	return
+}
+
+// A checkoutStorage stores the refs, index, config and submodules of a checkout in its own
+// directory, the objects are read from the mirror
+type checkoutStorage struct {
+	*filesystem.Storage
+	mirror storage.Storer
+}
+
+func (cs *checkoutStorage) EncodedObject(objectType plumbing.ObjectType, hash plumbing.Hash) (plumbing.EncodedObject, error) {
+	object, err := cs.mirror.EncodedObject(objectType, hash)
+	if err == plumbing.ErrObjectNotFound {
+		return cs.Storage.EncodedObject(objectType, hash)
+	}
+	return object, err
+}
+
+func (cs *checkoutStorage) HasEncodedObject(hash plumbing.Hash) error {
+	if err := cs.mirror.HasEncodedObject(hash); err != plumbing.ErrObjectNotFound {
+		return err
+	}
+	return cs.Storage.HasEncodedObject(hash)
+}
+
+func (cs *checkoutStorage) EncodedObjectSize(hash plumbing.Hash) (int64, error) {
+	size, err := cs.mirror.EncodedObjectSize(hash)
+	if err == plumbing.ErrObjectNotFound {
+		return cs.Storage.EncodedObjectSize(hash)
+	}
+	return size, err
+}
+
+func (cs *checkoutStorage) IterEncodedObjects(objectType plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	return cs.mirror.IterEncodedObjects(objectType)
+}
+
+// History of a shallow mirror ends at its shallow commits
+func (cs *checkoutStorage) Shallow() ([]plumbing.Hash, error) {
+	return cs.mirror.Shallow()
+}
+
+// Clone the mirror or fetch the new objects into it, and resolve the commit to scan
+func (mc *MirrorCache) update(ctx context.Context, mirrorDir string, repo *domain.Repo, strategy *domain.CloneStrategy, auth transport.AuthMethod) (mirror *git.Repository, hash plumbing.Hash, err error) {
+	tags := git.NoTags
+	if strategy.Tags {
+		tags = git.AllTags
+	}
+	mirror, err = git.PlainOpen(mirrorDir)
+	switch {
+	case err == git.ErrRepositoryNotExists:
+		mirror, err = git.PlainCloneContext(ctx, mirrorDir, true, cloneOptions(repo, strategy, auth))
+		if err == nil {
+			err = forgetOrigin(mirror, repo.Url)
+		}
+		if err != nil {
+			os.RemoveAll(mirrorDir)
+			return
+		}
+	case err != nil:
+		err = errMirrorCorrupt
+		return
+	default:
+		// Mirror has to be readable before fetching into it
+		if _, err = mirrorTarget(mirror, strategy.Branch); err != nil {
+			err = errMirrorCorrupt
+			return
+		}
+		// Repos of the different URLs and credentials share the mirror, each fetch reads the
+		// URL of its own repo with its own credential
+		err = mirror.FetchContext(ctx, &git.FetchOptions{
+			RemoteURL: repo.Url,
+			Auth:      auth,
+			Depth:     strategy.Depth,
+			Tags:      tags,
+			Force:     true,
+		})
+		if err == git.NoErrAlreadyUpToDate {
+			err = nil
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	hash, err = mirrorTarget(mirror, strategy.Branch)
+	if err != nil {
+		err = errMirrorCorrupt
+	}
+	return
+}
+
+// Keep the origin of the mirror without the credentials of the URL which cloned it, so they are
+// never used by the fetches of the other repos of the mirror
+func forgetOrigin(mirror *git.Repository, repoUrl string) error {
+	endpoint, err := transport.NewEndpoint(repoUrl)
+	if err != nil || (endpoint.User == "" && endpoint.Password == "") {
+		return err
+	}
+	endpoint.User, endpoint.Password = "", ""
+	cfg, err := mirror.Config()
+	if err != nil {
+		return err
+	}
+	cfg.Remotes[git.DefaultRemoteName].URLs = []string{endpoint.String()}
+	return mirror.SetConfig(cfg)
+}
+
+// Resolve the commit of the branch, HEAD branch of the mirror if it is not given.
+// The objects of the commit are read to verify the integrity of the mirror.
+func mirrorTarget(mirror *git.Repository, branch string) (hash plumbing.Hash, err error) {
+	if branch == "" {
+		var head *plumbing.Reference
+		head, err = mirror.Reference(plumbing.HEAD, false)
+		if err != nil {
+			return
+		}
+		branch = head.Target().Short()
+	}
+	// Fetch updates the remote branches of the mirror
+	ref, err := mirror.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, branch), true)
+	if err != nil {
+		ref, err = mirror.Reference(plumbing.NewBranchReferenceName(branch), true)
+		if err != nil {
+			return
+		}
+	}
+	commit, err := mirror.CommitObject(ref.Hash())
+	if err != nil {
+		return
+	}
+	if _, err = commit.Tree(); err != nil {
+		return
+	}
+	return ref.Hash(), nil
+}
+
+// Lock the mirror of the key, the returned function unlocks it
+func (mc *MirrorCache) lock(key string) func() {
+	mc.mu.Lock()
+	l, ok := mc.locks[key]
+	if !ok {
+		l = &mirrorLock{}
+		mc.locks[key] = l
+	}
+	l.users++
+	mc.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		mc.mu.Lock()
+		l.users--
+		if l.users == 0 {
+			delete(mc.locks, key)
+		}
+		mc.mu.Unlock()
+	}
+}
+
+// Remove the least recently used mirrors until the cache fits in the maximum size.
+// The mirrors which are in use are never removed.
+func (mc *MirrorCache) evict() {
+	if mc.MaxSize <= 0 {
+		return
+	}
+	// No scan can lock a mirror while the eviction is running
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	entries, err := os.ReadDir(mc.Folder)
+	if err != nil {
+		return
+	}
+	type mirrorUsage struct {
+		path   string
+		key    string
+		size   int64
+		usedAt time.Time
+	}
+	var (
+		mirrors []mirrorUsage
+		total   int64
+	)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(mc.Folder, entry.Name())
+		size := dirSize(path)
+		total += size
+		mirrors = append(mirrors, mirrorUsage{path: path, key: entry.Name(), size: size, usedAt: info.ModTime()})
+	}
+	sort.Slice(mirrors, func(i, j int) bool {
+		return mirrors[i].usedAt.Before(mirrors[j].usedAt)
+	})
+	for _, m := range mirrors {
+		if total <= mc.MaxSize {
+			return
+		}
+		if _, inUse := mc.locks[m.key]; inUse {
+			continue
+		}
+		if os.RemoveAll(m.path) == nil {
+			total -= m.size
+		}
+	}
+}
+
+// Total size of the files in the directory
+func dirSize(path string) (size int64) {
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return
+}
+
+// Name of the mirror directory of the repo URL and the history which the strategy fetches, so
+// the shallow and single branch mirrors are not used for the scans of the other histories
+func mirrorKey(repoUrl string, strategy *domain.CloneStrategy) string {
+	key := normalizeRepoUrl(repoUrl)
+	if strategy.SingleBranch {
+		key += "#" + strategy.Branch
+	}
+	if strategy.Depth > 0 {
+		key += fmt.Sprintf("@%d", strategy.Depth)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Normalize the repo URL, so the URLs of the same repo share the mirror. The protocol,
+// credentials, default port, trailing slash and .git suffix are dropped.
+func normalizeRepoUrl(repoUrl string) string {
+	endpoint, err := transport.NewEndpoint(repoUrl)
+	if err != nil {
+		return repoUrl
+	}
+	path := strings.TrimSuffix(strings.TrimSuffix(endpoint.Path, "/"), ".git")
+	if endpoint.Host == "" {
+		return path
+	}
+	host := strings.ToLower(endpoint.Host)
+	defaultPorts := map[string]int{"https": 443, "http": 80, "ssh": 22, "git": 9418}
+	if endpoint.Port != 0 && endpoint.Port != defaultPorts[endpoint.Protocol] {
+		host = fmt.Sprintf("%s:%d", host, endpoint.Port)
+	}
+	return host + "/" + strings.TrimPrefix(path, "/")
+}
@@ -0,0 +1,165 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/scanner/app/domain"
+)
+
+var errMemoryLimit = errors.New("objects of the repo exceed the memory limit")
+
+// Open the repo without a worktree, the hash is the commit of the strategy branch. The local
+// sources are opened in place, the other repos are read from their mirror when the mirror
+// cache is enabled, otherwise they are cloned. The returned function releases the repo after
+// the scan.
+func (sr *ScanRepository) openRepository(ctx context.Context, repo *domain.Repo, strategy *domain.CloneStrategy, auth transport.AuthMethod) (gitRepo *git.Repository, hash plumbing.Hash, release func(), err error) {
+	release = func() {}
+	switch {
+	case repo.SourceType == "local":
+		var path string
+		if path, err = localSourcePath(repo.Url, sr.LocalRoots); err != nil {
+			return
+		}
+		if gitRepo, err = git.PlainOpen(path); err != nil {
+			return
+		}
+		hash, err = localCommit(gitRepo, strategy.Branch)
+	case sr.MirrorCache != nil:
+		return sr.MirrorCache.open(ctx, repo, strategy, auth)
+	default:
+		return sr.cloneObjects(ctx, repo, strategy, auth)
+	}
+	return
+}
+
+// Clone the repo without a worktree. The small repos are cloned into the memory, the clone
+// moves to a bare repo of the clone folder when its objects exceed the memory limit. The
+// returned function removes the bare repo.
+func (sr *ScanRepository) cloneObjects(ctx context.Context, repo *domain.Repo, strategy *domain.CloneStrategy, auth transport.AuthMethod) (gitRepo *git.Repository, hash plumbing.Hash, release func(), err error) {
+	release = func() {}
+	if sr.MemoryMaxSize > 0 {
+		storage := &limitedStorage{Storage: memory.NewStorage(), maxSize: sr.MemoryMaxSize}
+		gitRepo, err = git.CloneContext(ctx, storage, nil, cloneOptions(repo, strategy, auth))
+		if !errors.Is(err, errMemoryLimit) {
+			if err == nil {
+				hash, err = headCommit(gitRepo)
+			}
+			return
+		}
+	}
+
+	directory, remove, err := sr.cloneDir()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			remove()
+		}
+	}()
+	if gitRepo, err = git.PlainCloneContext(ctx, directory, true, cloneOptions(repo, strategy, auth)); err != nil {
+		return
+	}
+	if hash, err = headCommit(gitRepo); err != nil {
+		return
+	}
+	release = remove
+	return
+}
+
+// Commit of the HEAD of the repo
+func headCommit(gitRepo *git.Repository) (plumbing.Hash, error) {
+	head, err := gitRepo.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return head.Hash(), nil
+}
+
+// A limitedStorage keeps the objects in the memory until their size exceeds the maximum
+type limitedStorage struct {
+	*memory.Storage
+	maxSize int64
+	size    int64
+}
+
+func (ls *limitedStorage) SetEncodedObject(object plumbing.EncodedObject) (plumbing.Hash, error) {
+	if ls.size += object.Size(); ls.size > ls.maxSize {
+		return plumbing.ZeroHash, errMemoryLimit
+	}
+	return ls.Storage.SetEncodedObject(object)
+}
+
+// Resolve the tree of the commit
+func commitTree(gitRepo *git.Repository, hash plumbing.Hash) (*object.Tree, error) {
+	commit, err := gitRepo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// Resolve the tree of the commit to scan without a worktree
+func (sr *ScanRepository) objectTree(ctx context.Context, repo *domain.Repo, strategy *domain.CloneStrategy, auth transport.AuthMethod) (tree *object.Tree, hash plumbing.Hash, release func(), err error) {
+	gitRepo, hash, release, err := sr.openRepository(ctx, repo, strategy, auth)
+	if err != nil {
+		return
+	}
+
+	if tree, err = commitTree(gitRepo, hash); err != nil {
+		release()
+	}
+	return
+}
+
+// Pass the blobs of the tree to the workers. The submodules and the symlinks are not scanned.
+func walkObjects(tree *object.Tree, sparsePaths []string, jobs chan<- scanJob) error {
+	// The tree is read before the workers read the blobs, as the object storage is not
+	// safe for the concurrent reads
+	var files []*object.File
+	err := tree.Files().ForEach(func(file *object.File) error {
+		if file.Mode == filemode.Symlink {
+			return nil
+		}
+		if len(sparsePaths) > 0 && !inSparsePaths(file.Name, sparsePaths) {
+			return nil
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var storageMu sync.Mutex
+	for _, file := range files {
+		jobs <- blobJob(file.Name, &file.Blob, &storageMu)
+	}
+	return nil
+}
+
+// Build the job which reads the blob while holding the lock of the object storage
+func blobJob(path string, blob *object.Blob, storageMu *sync.Mutex) scanJob {
+	return scanJob{
+		path: path,
+		blob: blob.Hash.String(),
+		read: func() ([]byte, error) {
+			storageMu.Lock()
+			defer storageMu.Unlock()
+			reader, err := blob.Reader()
+			if err != nil {
+				return nil, err
+			}
+			defer reader.Close()
+			return io.ReadAll(reader)
+		},
+	}
+}
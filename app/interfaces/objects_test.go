@@ -0,0 +1,61 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the scan of the git object storage
+func TestScanObjects(t *testing.T) {
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git-upload-pack is required to clone local repos")
+	}
+	source := testGitRepo(t, map[string]string{
+		"src/keys.go":   "var key = \"public_key_1\"",
+		"docs/guide.md": "public_key in the docs",
+	})
+	mirrorCache, err := NewMirrorCache(t.TempDir(), 0)
+	assert.NoError(t, err)
+
+	type storage struct {
+		mirrorCache   *MirrorCache
+		memoryMaxSize int64
+	}
+	// Repos which exceed the memory limit are cloned into the clone folder
+	for name, storage := range map[string]storage{"memory": {memoryMaxSize: 1 << 20}, "memory-limit": {memoryMaxSize: 1}, "disk": {}, "mirror": {mirrorCache: mirrorCache}} {
+		t.Run(name, func(t *testing.T) {
+			cloneFolder := t.TempDir()
+			scanRepository := &ScanRepository{
+				SearchPattern:         []string{"public_key"},
+				ScanCloneFolder:       cloneFolder,
+				ScanCloneFolderPrefix: "repo",
+				NoOfWorkers:           2,
+				MirrorCache:           storage.mirrorCache,
+				MemoryMaxSize:         storage.memoryMaxSize,
+				ScanMode:              "objects",
+			}
+			repo := &domain.Repo{Url: source, CloneStrategy: &domain.CloneStrategy{SparsePaths: []string{"src"}}}
+			scanData, err := scanRepository.Scan(context.Background(), repo, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, int8(3), scanData.Status)
+			assert.Len(t, scanData.Commit, 40)
+
+			var scanResult result
+			assert.NoError(t, json.Unmarshal([]byte(scanData.Result), &scanResult))
+			if assert.Len(t, scanResult.Findings, 1) {
+				assert.Equal(t, "src/keys.go", scanResult.Findings[0].Location.Path)
+				assert.Len(t, scanResult.Findings[0].Location.Blob, 40)
+			}
+			// Nothing is left in the clone folder
+			entries, err := os.ReadDir(cloneFolder)
+			assert.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package interfaces
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/scanner/app/domain"
+	"github.com/scanner/app/usecases"
+	"go.uber.org/zap"
+)
+
+// Phases of a queued scan. The files are scanned while they are walked, the scanning phase is
+// the rest of the files after the walk.
+const (
+	phaseQueued   = "queued"
+	phaseCloning  = "cloning"
+	phaseWalking  = "walking"
+	phaseScanning = "scanning"
+	phaseStoring  = "storing"
+	phaseDone     = "done"
+)
+
+type progressKey struct{}
+
+// A scanProgress is the state of a scan running on the instance. The workers of the
+// ScanRepository update its counters through the context of the scan, a nil progress ignores
+// the updates.
+type scanProgress struct {
+	discovered atomic.Int64
+	scanned    atomic.Int64
+	findings   atomic.Int64
+
+	mu          sync.Mutex
+	phase       string
+	subscribers map[chan domain.ScanProgress]struct{}
+}
+
+// Progress of the scan of the context, nil when it is not tracked
+func progressFrom(ctx context.Context) *scanProgress {
+	progress, _ := ctx.Value(progressKey{}).(*scanProgress)
+	return progress
+}
+
+// Move the scan to the phase, the subscribers get it at once
+func (p *scanProgress) setPhase(phase string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.phase = phase
+	p.mu.Unlock()
+	p.publish(p.snapshot())
+}
+
+// Count a file which the walk passed to the workers
+func (p *scanProgress) discover() {
+	if p != nil {
+		p.discovered.Add(1)
+	}
+}
+
+// Count a scanned file with its findings
+func (p *scanProgress) scan(findings int) {
+	if p != nil {
+		p.scanned.Add(1)
+		p.findings.Add(int64(findings))
+	}
+}
+
+func (p *scanProgress) snapshot() domain.ScanProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state()
+}
+
+// Current state of the progress, the caller holds the lock
+func (p *scanProgress) state() domain.ScanProgress {
+	return domain.ScanProgress{
+		Phase:           p.phase,
+		FilesDiscovered: p.discovered.Load(),
+		FilesScanned:    p.scanned.Load(),
+		Findings:        p.findings.Load(),
+	}
+}
+
+// Send the state to the subscribers. Each subscriber holds the latest state only, so a slow
+// subscriber skips the states which it missed instead of holding up the scan.
+func (p *scanProgress) publish(progress domain.ScanProgress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for updates := range p.subscribers {
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- progress
+	}
+}
+
+// A ProgressHub publishes the progress of the scans running on the instance to their
+// subscribers. The progress is stored with the scan result as well, so the subscribers of the
+// other instances can follow it.
+type ProgressHub struct {
+	ScanInteractor usecases.ScanInteractor
+	Logger         *zap.Logger
+	// Wait between the states of the counters which are published and stored
+	Interval time.Duration
+
+	mu    sync.Mutex
+	scans map[int64]*scanProgress
+}
+
+// NewProgressHub returns the progress hub of the instance which publishes the counters every
+// second.
+func NewProgressHub(scanInteractor usecases.ScanInteractor, logger *zap.Logger) *ProgressHub {
+	return &ProgressHub{
+		ScanInteractor: scanInteractor,
+		Logger:         logger,
+		Interval:       time.Second,
+		scans:          make(map[int64]*scanProgress),
+	}
+}
+
+// Track the progress of the scan of the result through the returned context. The returned
+// function ends the progress in the given phase and status, the subscribers are released
+// without a final state when the phase is empty.
+func (ph *ProgressHub) track(ctx context.Context, resultID int64) (context.Context, func(phase, status string)) {
+	progress := &scanProgress{phase: phaseCloning, subscribers: make(map[chan domain.ScanProgress]struct{})}
+	ph.mu.Lock()
+	ph.scans[resultID] = progress
+	ph.mu.Unlock()
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(ph.Interval)
+		defer ticker.Stop()
+		var last domain.ScanProgress
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if current := progress.snapshot(); current != last {
+					progress.publish(current)
+					ph.store(resultID, &current)
+					last = current
+				}
+			}
+		}
+	}()
+
+	finish := func(phase, status string) {
+		close(stop)
+		<-stopped
+		ph.mu.Lock()
+		delete(ph.scans, resultID)
+		ph.mu.Unlock()
+		if phase != "" {
+			final := progress.snapshot()
+			final.Phase = phase
+			final.Status = status
+			progress.publish(final)
+			ph.store(resultID, &final)
+		}
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		for updates := range progress.subscribers {
+			close(updates)
+		}
+		progress.subscribers = nil
+	}
+	return context.WithValue(ctx, progressKey{}, progress), finish
+}
+
+// Subscribe to the progress of the scan of the result, false when the scan is not running on
+// the instance. The current state is the first one of the channel, which is closed when the
+// scan ends. The returned function ends the subscription.
+func (ph *ProgressHub) Subscribe(resultID int64) (<-chan domain.ScanProgress, func(), bool) {
+	ph.mu.Lock()
+	progress, ok := ph.scans[resultID]
+	ph.mu.Unlock()
+	if !ok {
+		return nil, nil, false
+	}
+
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	// Scan ended before the subscription
+	if progress.subscribers == nil {
+		return nil, nil, false
+	}
+	updates := make(chan domain.ScanProgress, 1)
+	updates <- progress.state()
+	progress.subscribers[updates] = struct{}{}
+	unsubscribe := func() {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		delete(progress.subscribers, updates)
+	}
+	return updates, unsubscribe, true
+}
+
+// Store the progress for the subscribers of the other instances, the scan goes on when it
+// cannot be stored
+func (ph *ProgressHub) store(resultID int64, progress *domain.ScanProgress) {
+	if err := ph.ScanInteractor.StoreProgress(resultID, progress); err != nil {
+		ph.Logger.Error(fmt.Sprintf("unable to store the progress of scan %d: %s", resultID, err))
+	}
+}
+
+// Progress of the stored scan result, for the scans which are not running on the instance
+func resultProgress(scanResult *domain.ScanResult) domain.ScanProgress {
+	progress := domain.ScanProgress{}
+	if scanResult.Progress != nil {
+		progress = *scanResult.Progress
+	}
+	switch status := statusName(scanResult.Status); status {
+	case "Queued":
+		// Counters of a retried scan start again
+		return domain.ScanProgress{Phase: phaseQueued}
+	case "In Progress":
+		// Progress is stored after the first phase has started
+		if progress.Phase == "" || progress.Phase == phaseQueued {
+			progress.Phase = phaseCloning
+		}
+		progress.Status = ""
+	default:
+		progress.Phase = phaseDone
+		progress.Status = status
+	}
+	return progress
+}
+
+// Name of the status of the stored result, which is read as its number
+func statusName(status string) string {
+	if number, err := strconv.ParseInt(status, 10, 8); err == nil {
+		return statusText(int8(number))
+	}
+	return status
+}
@@ -0,0 +1,118 @@
+package interfaces
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scanner/app/domain"
+	"github.com/scanner/app/usecases"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// Scan repository which keeps the stored progress, the mocks cannot be used inside the package
+// because they import it
+type progressRepository struct {
+	usecases.ScanRepository
+	mu     sync.Mutex
+	stored map[int64]domain.ScanProgress
+}
+
+func (pr *progressRepository) StoreProgress(resultID int64, progress *domain.ScanProgress) error {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if pr.stored == nil {
+		pr.stored = make(map[int64]domain.ScanProgress)
+	}
+	pr.stored[resultID] = *progress
+	return nil
+}
+
+// Receive the next state of the subscription
+func nextProgress(t *testing.T, updates <-chan domain.ScanProgress) domain.ScanProgress {
+	select {
+	case progress := <-updates:
+		return progress
+	case <-time.After(5 * time.Second):
+		t.Fatal("no progress was published")
+	}
+	return domain.ScanProgress{}
+}
+
+// Test the progress of a scan running on the instance
+func TestProgressHub(t *testing.T) {
+	progressRepository := &progressRepository{}
+	hub := NewProgressHub(usecases.ScanInteractor{ScanRepository: progressRepository}, zap.NewNop())
+	hub.Interval = 10 * time.Millisecond
+	ctx, finish := hub.track(context.Background(), 4)
+	progress := progressFrom(ctx)
+
+	updates, unsubscribe, ok := hub.Subscribe(4)
+	assert.True(t, ok)
+	defer unsubscribe()
+	assert.Equal(t, domain.ScanProgress{Phase: phaseCloning}, nextProgress(t, updates))
+
+	progress.setPhase(phaseWalking)
+	assert.Equal(t, phaseWalking, nextProgress(t, updates).Phase)
+	progress.discover()
+	progress.discover()
+	progress.scan(3)
+	assert.Eventually(t, func() bool {
+		select {
+		case current := <-updates:
+			return current == domain.ScanProgress{Phase: phaseWalking, FilesDiscovered: 2, FilesScanned: 1, Findings: 3}
+		default:
+			return false
+		}
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// Late subscriber gets the current state first
+	late, unsubscribeLate, ok := hub.Subscribe(4)
+	assert.True(t, ok)
+	defer unsubscribeLate()
+	assert.Equal(t, int64(2), nextProgress(t, late).FilesDiscovered)
+
+	finish(phaseDone, "Success")
+	final := nextProgress(t, updates)
+	assert.Equal(t, phaseDone, final.Phase)
+	assert.Equal(t, "Success", final.Status)
+	assert.Equal(t, int64(3), final.Findings)
+	_, open := <-updates
+	assert.False(t, open)
+	// Final state is stored for the other instances
+	assert.Equal(t, final, progressRepository.stored[4])
+
+	_, _, ok = hub.Subscribe(4)
+	assert.False(t, ok)
+}
+
+// Test the progress of the stored scan results
+func TestResultProgress(t *testing.T) {
+	counters := &domain.ScanProgress{Phase: phaseWalking, FilesDiscovered: 5, FilesScanned: 4, Findings: 1}
+	assert.Equal(t, domain.ScanProgress{Phase: phaseQueued}, resultProgress(&domain.ScanResult{Status: "1", Progress: counters}))
+	assert.Equal(t, *counters, resultProgress(&domain.ScanResult{Status: "2", Progress: counters}))
+	assert.Equal(t, phaseCloning, resultProgress(&domain.ScanResult{Status: "2"}).Phase)
+	assert.Equal(t, domain.ScanProgress{Phase: phaseDone, FilesDiscovered: 5, FilesScanned: 4, Findings: 1, Status: "Failure"},
+		resultProgress(&domain.ScanResult{Status: "4", Progress: counters}))
+	assert.Equal(t, "Cancelled", resultProgress(&domain.ScanResult{Status: "Cancelled"}).Status)
+}
+
+// Test the counters of the workers of a tracked scan
+func TestScanProgressCounters(t *testing.T) {
+	source := testGitRepo(t, map[string]string{"a.go": "public_key_1", "b.go": "nothing", "c.go": "public_key_2"})
+	scanRepository := &ScanRepository{SearchPattern: []string{"public_key"}, NoOfWorkers: 2, LocalRoots: []string{filepath.Dir(source)}}
+	hub := NewProgressHub(usecases.ScanInteractor{ScanRepository: &progressRepository{}}, zap.NewNop())
+	ctx, finish := hub.track(context.Background(), 5)
+	defer finish("", "")
+
+	_, err := scanRepository.Scan(ctx, &domain.Repo{Url: "file://" + source, SourceType: "local"}, nil)
+	assert.NoError(t, err)
+	progress := progressFrom(ctx).snapshot()
+	assert.Equal(t, phaseScanning, progress.Phase)
+	assert.Equal(t, progress.FilesDiscovered, progress.FilesScanned)
+	assert.GreaterOrEqual(t, progress.FilesScanned, int64(3))
+	assert.Equal(t, int64(2), progress.Findings)
+}
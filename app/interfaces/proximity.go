@@ -0,0 +1,188 @@
+package interfaces
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Struct for the keyword proximity details of a finding. The matched value is never recorded.
+type proximity struct {
+	Keyword    string  `json:"keyword"`
+	Distance   int     `json:"distance"`
+	Entropy    float64 `json:"entropy"`
+	Confidence float64 `json:"confidence"`
+}
+
+var (
+	// Quoted or bare values which are long enough to be a secret
+	proximityValuePattern = regexp.MustCompile(`"([^"\s]{8,})"|'([^'\s]{8,})'|([A-Za-z0-9+/_\-.!@#$%^&*~]{8,})`)
+	// Operators of an assignment in code and config files
+	assignmentPattern = regexp.MustCompile(`:=|=>|[=:]`)
+)
+
+// A proximityDetector reports the values which follow a keyword, e.g. db_password = "x8Fj...".
+// The value has to match the value pattern or have at least the minimum entropy, and it has to
+// be within the maximum distance of the keyword or assigned to it.
+type proximityDetector struct {
+	keywords     []string
+	valuePattern *regexp.Regexp
+	minEntropy   float64
+	maxDistance  int
+}
+
+// Check each line of the content. An assignment whose value is on the next line, e.g. a YAML
+// "key:" followed by the indented value or a line ending with a backslash, is matched across
+// the two lines and reported at the keyword. Values which span more lines are not matched.
+func (pd *proximityDetector) detect(path string, content []byte) (fileFindings findings) {
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		details, col, ok := pd.match(line)
+		if !ok && i+1 < len(lines) {
+			if joined, continued := continuedLine(line, lines[i+1]); continued {
+				// Keywords of the next line are reported on their own line
+				if details, col, ok = pd.match(joined); col > len(line) {
+					ok = false
+				}
+			}
+		}
+		if ok {
+			fileFindings = append(fileFindings, finding{
+				ErrorType: "sast",
+				RuleID:    "G406",
+				Location: location{
+					Path:      path,
+					Positions: []position{{Begin: begin{Line: fmt.Sprintf("%d", i+1), Cols: []string{fmt.Sprintf("%d", col)}}}},
+				},
+				Metadata: metadata{
+					Description: "Secret value is assigned to a sensitive keyword",
+					Severity:    confidenceSeverity(details.Confidence),
+					Proximity:   details,
+				},
+			})
+		}
+	}
+	return
+}
+
+// Join the line with the next one when it ends with an assignment operator, a YAML block
+// indicator or a line continuation, so the value on the next line belongs to the assignment
+func continuedLine(line, next string) (string, bool) {
+	trimmed := strings.TrimRight(line, " \t")
+	for _, suffix := range []string{"=", ":", "|", ">", "\\"} {
+		if strings.HasSuffix(trimmed, suffix) {
+			return strings.TrimSuffix(trimmed, "\\") + " " + strings.TrimSpace(strings.TrimSuffix(next, "\r")), true
+		}
+	}
+	return "", false
+}
+
+// Find the most confident keyword and value pair of the line
+func (pd *proximityDetector) match(line string) (best *proximity, col int, ok bool) {
+	lower := strings.ToLower(line)
+	values := proximityValuePattern.FindAllStringSubmatchIndex(line, -1)
+	for _, keyword := range pd.keywords {
+		for offset := 0; ; {
+			idx := strings.Index(lower[offset:], keyword)
+			if idx < 0 {
+				break
+			}
+			kwStart := offset + idx
+			kwEnd := kwStart + len(keyword)
+			offset = kwEnd
+			for _, loc := range values {
+				start, end := valueBounds(loc)
+				// Only the values after the keyword, excluding the identifier which contains it
+				if loc[0] < kwEnd {
+					continue
+				}
+				details, matched := pd.score(keyword, line[kwEnd:loc[0]], line[start:end], loc[0] != start)
+				if matched && (best == nil || details.Confidence > best.Confidence) {
+					best, col, ok = details, kwStart+1, true
+				}
+			}
+		}
+	}
+	return
+}
+
+// Score the value which is separated from the keyword by the gap
+func (pd *proximityDetector) score(keyword, gap, value string, quoted bool) (details *proximity, matched bool) {
+	assigned := assignmentPattern.MatchString(gap) && !strings.ContainsAny(gap, ";,")
+	if len(gap) > pd.maxDistance && !assigned {
+		return
+	}
+	if isPlaceholder(value) {
+		return
+	}
+	entropy := shannonEntropy(value)
+	patternMatched := pd.valuePattern != nil && pd.valuePattern.MatchString(value)
+	if !patternMatched && entropy < pd.minEntropy {
+		return
+	}
+
+	confidence := 0.4
+	if assigned {
+		confidence += 0.2
+	}
+	if quoted {
+		confidence += 0.1
+	}
+	if patternMatched {
+		confidence += 0.2
+	}
+	// Higher entropy than required makes a random secret more likely
+	confidence += math.Min(0.2, math.Max(0, entropy-pd.minEntropy)/5)
+	// Far away values are less likely to belong to the keyword
+	if len(gap) > pd.maxDistance/2 {
+		confidence -= 0.1
+	}
+	confidence = math.Round(math.Min(1, confidence)*100) / 100
+	return &proximity{
+		Keyword:    keyword,
+		Distance:   len(gap),
+		Entropy:    math.Round(entropy*100) / 100,
+		Confidence: confidence,
+	}, true
+}
+
+// Return the bounds of the captured value of a proximityValuePattern match
+func valueBounds(loc []int) (int, int) {
+	for i := 2; i < len(loc); i += 2 {
+		if loc[i] >= 0 {
+			return loc[i], loc[i+1]
+		}
+	}
+	return loc[0], loc[1]
+}
+
+// Calculate the Shannon entropy of the value in bits per character
+func shannonEntropy(value string) (entropy float64) {
+	if value == "" {
+		return
+	}
+	counts := make(map[rune]int)
+	for _, r := range value {
+		counts[r]++
+	}
+	length := float64(len([]rune(value)))
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return
+}
+
+// Map the confidence of a finding to its severity
+func confidenceSeverity(confidence float64) string {
+	switch {
+	case confidence >= 0.8:
+		return "HIGH"
+	case confidence >= 0.6:
+		return "MEDIUM"
+	default:
+		return "LOW"
+	}
+}
@@ -0,0 +1,88 @@
+package interfaces
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test keyword proximity detection
+func TestProximityDetector(t *testing.T) {
+	pd := &proximityDetector{
+		keywords:    []string{"password", "api_key"},
+		minEntropy:  3.5,
+		maxDistance: 40,
+	}
+
+	t.Run("assignment", func(t *testing.T) {
+		results := pd.detect("settings.py", []byte("DEBUG = False\ndb_password = \"x8Fj2kQz9LmP4wRt\"\n"))
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "G406", results[0].RuleID)
+		assert.Equal(t, "2", results[0].Location.Positions[0].Begin.Line)
+		assert.Equal(t, []string{"4"}, results[0].Location.Positions[0].Begin.Cols)
+		assert.Equal(t, "password", results[0].Metadata.Proximity.Keyword)
+		assert.Equal(t, "HIGH", results[0].Metadata.Severity)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		results := pd.detect("config.json", []byte(`{"API_KEY": "Zm9vYmFyYmF6cXV4MTIz"}`))
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "api_key", results[0].Metadata.Proximity.Keyword)
+	})
+
+	t.Run("low-entropy", func(t *testing.T) {
+		results := pd.detect("settings.py", []byte(`password = "aaaaaaaaaaaa"`))
+		assert.Equal(t, 0, len(results))
+	})
+
+	t.Run("placeholder", func(t *testing.T) {
+		results := pd.detect("settings.py", []byte(`password = "${DB_PASSWORD}"`))
+		assert.Equal(t, 0, len(results))
+	})
+
+	t.Run("too-far", func(t *testing.T) {
+		results := pd.detect("README.md", []byte("Reset your password by following the steps in the guide, then x8Fj2kQz9LmP4wRt"))
+		assert.Equal(t, 0, len(results))
+	})
+
+	t.Run("yaml-continuation", func(t *testing.T) {
+		results := pd.detect("config.yml", []byte("database:\n  password:\n    x8Fj2kQz9LmP4wRt\n"))
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "2", results[0].Location.Positions[0].Begin.Line)
+		assert.Equal(t, []string{"3"}, results[0].Location.Positions[0].Begin.Cols)
+	})
+
+	t.Run("line-continuation", func(t *testing.T) {
+		results := pd.detect("settings.py", []byte("API_KEY = \\\n    \"Zm9vYmFyYmF6cXV4MTIz\"\n"))
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "1", results[0].Location.Positions[0].Begin.Line)
+	})
+
+	t.Run("next-line-keyword", func(t *testing.T) {
+		// Keyword of the next line is not reported twice
+		results := pd.detect("settings.py", []byte("DEBUG =\npassword = \"x8Fj2kQz9LmP4wRt\"\n"))
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "2", results[0].Location.Positions[0].Begin.Line)
+	})
+
+	t.Run("long-line", func(t *testing.T) {
+		// Lines longer than the default buffer of a bufio.Scanner do not end the detection
+		content := strings.Repeat("a", 70000) + "\npassword = \"x8Fj2kQz9LmP4wRt\"\n"
+		results := pd.detect("bundle.js", []byte(content))
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "2", results[0].Location.Positions[0].Begin.Line)
+	})
+
+	t.Run("value-pattern", func(t *testing.T) {
+		patternDetector := &proximityDetector{
+			keywords:     []string{"token"},
+			valuePattern: regexp.MustCompile(`^ghp_[A-Za-z0-9]{10,}$`),
+			minEntropy:   10,
+			maxDistance:  40,
+		}
+		results := patternDetector.detect("ci.yml", []byte("token: ghp_aaaaaaaaaaaaaaaa"))
+		assert.Equal(t, 1, len(results))
+	})
+}
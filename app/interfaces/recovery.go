@@ -0,0 +1,334 @@
+package interfaces
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scanner/app/usecases"
+	"go.uber.org/zap"
+)
+
+// A Recovery recovers the scans of the instances which died while the scans were running.
+// Every instance renews its heartbeat, and the running scans of the instances which have not
+// renewed it for the owner timeout are queued again when they have a job, otherwise they are
+// failed as interrupted. The clone folder is swept of the clone directories which no scan of the
+// instance uses and of the clone directories of the dead instances, so the instances can share
+// the clone folder.
+type Recovery struct {
+	ScanInteractor usecases.ScanInteractor
+	Logger         *zap.Logger
+	// Wait between the heartbeats and the recoveries
+	Interval time.Duration
+	// Instances without a heartbeat for the timeout are dead
+	OwnerTimeout time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewRecovery returns the recovery of the instance which runs every minute, the instances are
+// dead after 3 minutes without a heartbeat.
+func NewRecovery(scanInteractor usecases.ScanInteractor, logger *zap.Logger) *Recovery {
+	return &Recovery{
+		ScanInteractor: scanInteractor,
+		Logger:         logger,
+		Interval:       time.Minute,
+		OwnerTimeout:   3 * time.Minute,
+		stop:           make(chan struct{}),
+	}
+}
+
+// Start recovers the scans at once, so the heartbeat of the instance is renewed before its
+// scans start, and then in the background
+func (rc *Recovery) Start() {
+	rc.tick()
+	rc.wg.Add(1)
+	go func() {
+		defer rc.wg.Done()
+		ticker := time.NewTicker(rc.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rc.stop:
+				return
+			case <-ticker.C:
+				rc.tick()
+			}
+		}
+	}()
+}
+
+// Close stops the recovery
+func (rc *Recovery) Close() {
+	close(rc.stop)
+	rc.wg.Wait()
+}
+
+// Renew the heartbeat of the instance, recover the scans of the dead instances and sweep the
+// orphaned clone directories
+func (rc *Recovery) tick() {
+	if err := rc.ScanInteractor.Heartbeat(); err != nil {
+		rc.Logger.Error(fmt.Sprintf("unable to renew the heartbeat of the instance: %s", err))
+	}
+	requeued, failed, err := rc.ScanInteractor.Recover(rc.OwnerTimeout)
+	if err != nil {
+		rc.Logger.Error(fmt.Sprintf("unable to recover the interrupted scans: %s", err))
+	}
+	for _, id := range requeued {
+		rc.Logger.Info(fmt.Sprintf("interrupted scan %d is queued again", id))
+	}
+	for _, id := range failed {
+		rc.Logger.Info(fmt.Sprintf("interrupted scan %d is failed", id))
+	}
+	removed, err := rc.ScanInteractor.SweepClones(rc.OwnerTimeout)
+	if err != nil {
+		rc.Logger.Error(fmt.Sprintf("unable to sweep the clone folder: %s", err))
+	}
+	for _, directory := range removed {
+		rc.Logger.Info(fmt.Sprintf("orphaned clone directory %s is removed", directory))
+	}
+}
+
+// Heartbeat is to renew the heartbeat of the instance which owns the running entities.
+func (sr *ScanRepository) Heartbeat() (err error) {
+	query := `
+		INSERT INTO scan_instances (
+			owner,
+			heartbeat_time
+		)
+		VALUES (
+			?,
+			UTC_TIMESTAMP(6)
+		)
+		ON DUPLICATE KEY UPDATE heartbeat_time = UTC_TIMESTAMP(6)
+	`
+	_, err = sr.SQLHandler.Exec(query, sr.Owner)
+
+	return
+}
+
+// Recover is to queue again the running entities of the dead instances which have a job, and to
+// fail the others as interrupted. The queued entities without a job, of the instances which died
+// before queueing it, are failed as well. The entities of the jobs which are leased, or which
+// wait for a retry, are kept as they are.
+func (sr *ScanRepository) Recover(ownerTimeout time.Duration) (requeued []int64, failed []int64, err error) {
+	const query = `
+		SELECT
+			sr.id,
+			j.id
+		FROM
+			scan_results sr
+		LEFT JOIN
+			scan_jobs j
+		ON j.result_id = sr.id
+		WHERE
+			(
+				(
+					sr.status = 2
+				AND NOT EXISTS (
+					SELECT 1
+					FROM scan_instances i
+					WHERE
+						i.owner = sr.owner
+					AND i.heartbeat_time >= DATE_SUB(UTC_TIMESTAMP(6), INTERVAL ? MICROSECOND)
+				)
+				)
+			OR (
+					sr.status = 1
+				AND j.id IS NULL
+				AND sr.queue_time < DATE_SUB(UTC_TIMESTAMP(6), INTERVAL ? MICROSECOND)
+				)
+			)
+		AND (j.lease_expires_time IS NULL OR j.lease_expires_time < UTC_TIMESTAMP(6))
+		FOR UPDATE OF sr SKIP LOCKED
+	`
+	tx, err := sr.SQLHandler.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	row, err := tx.Query(query, ownerTimeout.Microseconds(), ownerTimeout.Microseconds())
+	if err != nil {
+		return
+	}
+	for row.Next() {
+		var (
+			id    int64
+			jobID sql.NullInt64
+		)
+		if err = row.Scan(&id, &jobID); err != nil {
+			row.Close()
+			return
+		}
+		if jobID.Valid {
+			requeued = append(requeued, id)
+		} else {
+			failed = append(failed, id)
+		}
+	}
+	row.Close()
+
+	const requeue = `
+		UPDATE scan_results
+		SET
+			status = 1,
+			owner = NULL
+		WHERE
+			id = ?
+	`
+	for _, id := range requeued {
+		if _, err = tx.Exec(requeue, id); err != nil {
+			return
+		}
+	}
+	const fail = `
+		UPDATE scan_results
+		SET
+			status = 4,
+			result = ?,
+			end_time = ?
+		WHERE
+			id = ?
+	`
+	result, _ := json.Marshal(map[string]string{"error": "interrupted"})
+	for _, id := range failed {
+		if _, err = tx.Exec(fail, string(result), time.Now().UTC(), id); err != nil {
+			return
+		}
+	}
+	const forget = `
+		DELETE FROM scan_instances
+		WHERE
+			heartbeat_time < DATE_SUB(UTC_TIMESTAMP(6), INTERVAL ? MICROSECOND)
+	`
+	if _, err = tx.Exec(forget, ownerTimeout.Microseconds()); err != nil {
+		return
+	}
+	err = tx.Commit()
+
+	return
+}
+
+// Create the temporary directory of a scan in the clone folder, the directory is not swept until
+// the returned function removes it. The name of the directory holds the owner of the instance, so
+// the instances which share the clone folder sweep only the directories of the dead instances.
+func (sr *ScanRepository) cloneDir() (directory string, remove func(), err error) {
+	sr.clonesMu.Lock()
+	defer sr.clonesMu.Unlock()
+	if directory, err = ioutil.TempDir(sr.ScanCloneFolder, sr.ScanCloneFolderPrefix+sr.Owner+"-"); err != nil {
+		return
+	}
+	if sr.clones == nil {
+		sr.clones = make(map[string]struct{})
+	}
+	sr.clones[directory] = struct{}{}
+	remove = func() {
+		os.RemoveAll(directory)
+		sr.clonesMu.Lock()
+		defer sr.clonesMu.Unlock()
+		delete(sr.clones, directory)
+	}
+	return
+}
+
+// SweepClones removes the clone directories which no scan uses, the directories of the instance
+// which no scan of it uses and the directories of the instances without a heartbeat for the owner
+// timeout, they are left behind by a stopped instance. Only the directories of the clone folder
+// prefix, an owner and a random number are removed, nothing is removed without a prefix.
+func (sr *ScanRepository) SweepClones(ownerTimeout time.Duration) (removed []string, err error) {
+	if sr.ScanCloneFolderPrefix == "" {
+		return
+	}
+	const query = `
+		SELECT
+			owner
+		FROM
+			scan_instances
+		WHERE
+			heartbeat_time >= DATE_SUB(UTC_TIMESTAMP(6), INTERVAL ? MICROSECOND)
+	`
+	rows, err := sr.SQLHandler.Query(query, ownerTimeout.Microseconds())
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	live := make(map[string]struct{})
+	for rows.Next() {
+		var owner string
+		if err = rows.Scan(&owner); err != nil {
+			return
+		}
+		live[owner] = struct{}{}
+	}
+	return sr.sweepClones(live)
+}
+
+// Remove the clone directories of the instance which no scan uses and the directories of the
+// owners which are not live
+func (sr *ScanRepository) sweepClones(live map[string]struct{}) (removed []string, err error) {
+	folder := sr.ScanCloneFolder
+	if folder == "" {
+		folder = os.TempDir()
+	}
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return
+	}
+
+	var orphaned []string
+	sr.clonesMu.Lock()
+	for _, entry := range entries {
+		owner, ok := cloneOwner(entry.Name(), sr.ScanCloneFolderPrefix)
+		if !entry.IsDir() || !ok {
+			continue
+		}
+		directory := filepath.Join(folder, entry.Name())
+		if owner == sr.Owner {
+			if _, ok := sr.clones[directory]; !ok {
+				orphaned = append(orphaned, directory)
+			}
+			continue
+		}
+		if _, ok := live[owner]; !ok {
+			orphaned = append(orphaned, directory)
+		}
+	}
+	sr.clonesMu.Unlock()
+
+	// New directories get new names, so the orphaned ones are removed without the lock
+	for _, directory := range orphaned {
+		if err = os.RemoveAll(directory); err != nil {
+			return
+		}
+		removed = append(removed, directory)
+	}
+	return
+}
+
+// Owner of the clone directory named by the prefix, the owner and a random number, false when
+// the name is not the name of a clone directory
+func cloneOwner(name, prefix string) (owner string, ok bool) {
+	rest := strings.TrimPrefix(name, prefix)
+	separator := strings.LastIndex(rest, "-")
+	if rest == name || separator <= 0 {
+		return "", false
+	}
+	random := rest[separator+1:]
+	if random == "" || strings.Trim(random, "0123456789") != "" {
+		return "", false
+	}
+	return rest[:separator], true
+}
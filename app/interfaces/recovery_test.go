@@ -0,0 +1,88 @@
+package interfaces_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scanner/app/domain/mocks"
+	"github.com/scanner/app/interfaces"
+	"github.com/scanner/app/usecases"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// Test the recovery of the running scans of the dead instances
+func TestScanRecover(t *testing.T) {
+	tx := new(mocks.Tx)
+	mockSQLHandler := new(mocks.SQLHandler)
+	mockSQLHandler.On("Begin").Return(tx, nil).Once()
+	timeout := (3 * time.Minute).Microseconds()
+	// Scan 4 has a job, scan 5 has none
+	rows := mockRows([]interface{}{int64(4), int64(9)}, []interface{}{int64(5), nil})
+	tx.On("Query", statement("FOR UPDATE OF sr SKIP LOCKED"), []interface{}{timeout, timeout}).Return(rows, nil).Once()
+	tx.On("Exec", statement("status = 1"), []interface{}{int64(4)}).Return(nil, nil).Once()
+	tx.On("Exec", statement("status = 4"), mock.MatchedBy(func(args []interface{}) bool {
+		return len(args) == 3 && args[0] == `{"error":"interrupted"}` && args[2] == int64(5)
+	})).Return(nil, nil).Once()
+	tx.On("Exec", statement("DELETE FROM scan_instances"), []interface{}{timeout}).Return(nil, nil).Once()
+	tx.On("Commit").Return(nil).Once()
+
+	requeued, failed, err := (&interfaces.ScanRepository{SQLHandler: mockSQLHandler}).Recover(3 * time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4}, requeued)
+	assert.Equal(t, []int64{5}, failed)
+	tx.AssertExpectations(t)
+	rows.AssertExpectations(t)
+	tx.AssertNotCalled(t, "Rollback")
+}
+
+// Test the heartbeat of the instance
+func TestScanHeartbeat(t *testing.T) {
+	mockSQLHandler := new(mocks.SQLHandler)
+	mockSQLHandler.On("Exec", statement("INSERT INTO scan_instances"), []interface{}{"worker-1"}).Return(nil, nil).Once()
+	assert.NoError(t, (&interfaces.ScanRepository{SQLHandler: mockSQLHandler, Owner: "worker-1"}).Heartbeat())
+	mockSQLHandler.AssertExpectations(t)
+}
+
+// Test the sweep of the clone directories of the dead instances
+func TestScanSweepClones(t *testing.T) {
+	folder := t.TempDir()
+	live := filepath.Join(folder, "repoworker-2-1")
+	dead := filepath.Join(folder, "repoworker-3-2")
+	for _, directory := range []string{live, dead} {
+		assert.NoError(t, os.Mkdir(directory, 0755))
+	}
+	mockSQLHandler := new(mocks.SQLHandler)
+	mockSQLHandler.On("Query", statement("FROM\n\t\t\tscan_instances"), []interface{}{(3 * time.Minute).Microseconds()}).Return(mockRows([]interface{}{"worker-1"}, []interface{}{"worker-2"}), nil).Once()
+
+	scanRepository := &interfaces.ScanRepository{SQLHandler: mockSQLHandler, Owner: "worker-1", ScanCloneFolder: folder, ScanCloneFolderPrefix: "repo"}
+	removed, err := scanRepository.SweepClones(3 * time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{dead}, removed)
+	assert.DirExists(t, live)
+	mockSQLHandler.AssertExpectations(t)
+
+	// Nothing is swept when the live instances are unknown
+	mockSQLHandler.On("Query", mock.Anything, mock.Anything).Return(nil, errors.New("connection lost")).Once()
+	_, err = scanRepository.SweepClones(3 * time.Minute)
+	assert.EqualError(t, err, "connection lost")
+	assert.DirExists(t, live)
+}
+
+// Test the heartbeat and the recovery of the instance
+func TestRecovery(t *testing.T) {
+	mockScanRepository := new(mocks.ScanRepository)
+	mockScanRepository.On("Heartbeat").Return(nil).Once()
+	mockScanRepository.On("Recover", 3*time.Minute).Return([]int64{4}, []int64{5}, nil).Once()
+	mockScanRepository.On("SweepClones", 3*time.Minute).Return([]string{"/tmp/repo42"}, nil).Once()
+	recovery := interfaces.NewRecovery(usecases.ScanInteractor{ScanRepository: mockScanRepository}, zap.NewNop())
+	recovery.Interval = time.Hour
+	// First recovery runs before Start returns
+	recovery.Start()
+	recovery.Close()
+	mockScanRepository.AssertExpectations(t)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -20,23 +21,33 @@ import (
 type RepoController struct {
 	RepoInteractor usecases.RepoInteractor
 	Logger         *zap.Logger
+	// Allowed root directories of the local sources
+	LocalRoots []string
 }
 
 // Struct for validation
 type RepoData struct {
-	name string
-	url  string
+	name       string
+	url        string
+	sourceType string
+	team       string
 }
 
 // NewRepoController create new instance of repo.
 func NewRepoController(sqlHandler SQLHandler, logger *zap.Logger) *RepoController {
+	credentialKey, err := loadCredentialKey()
+	if err != nil {
+		logger.Error(fmt.Sprintf("invalid CREDENTIAL_KEY: %s", err))
+	}
 	return &RepoController{
 		RepoInteractor: usecases.RepoInteractor{
 			RepoRepository: &RepoRepository{
-				SQLHandler: sqlHandler,
+				SQLHandler:    sqlHandler,
+				CredentialKey: credentialKey,
 			},
 		},
-		Logger: logger,
+		Logger:     logger,
+		LocalRoots: loadLocalRoots(),
 	}
 }
 
@@ -84,9 +95,15 @@ func (rc *RepoController) Create(w http.ResponseWriter, r *http.Request) {
 	rc.Logger.Info(fmt.Sprintf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL))
 	repoName := r.PostFormValue("name")
 	repoUrl := r.PostFormValue("url")
+	sourceType := r.PostFormValue("source_type")
+	if sourceType == "" {
+		sourceType = "git"
+	}
 	repoData := &RepoData{
-		name: repoName,
-		url:  repoUrl,
+		name:       repoName,
+		url:        repoUrl,
+		sourceType: sourceType,
+		team:       r.PostFormValue("team"),
 	}
 	err := rc.validate(repoData)
 	if err != nil {
@@ -94,12 +111,43 @@ func (rc *RepoController) Create(w http.ResponseWriter, r *http.Request) {
 		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
+	credential, err := rc.credential(r)
+	if err != nil {
+		rc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	cloneStrategy, err := rc.cloneStrategy(r)
+	if err != nil {
+		rc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	maxRetries, err := rc.maxRetries(r)
+	if err != nil {
+		rc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	schedule, nextScanTime, err := rc.schedule(r)
+	if err != nil {
+		rc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 	timeNow := time.Now().UTC()
 	repo := &domain.Repo{
-		Name:        repoName,
-		Url:         repoUrl,
-		CreatedTime: &timeNow,
-		UpdatedTime: &timeNow,
+		Name:          repoName,
+		Url:           repoUrl,
+		SourceType:    sourceType,
+		Team:          repoData.team,
+		Credential:    credential,
+		CloneStrategy: cloneStrategy,
+		MaxRetries:    maxRetries,
+		Schedule:      schedule,
+		NextScanTime:  nextScanTime,
+		CreatedTime:   &timeNow,
+		UpdatedTime:   &timeNow,
 	}
 	newRepo, err := rc.RepoInteractor.Store(repo)
 	if err != nil {
@@ -120,11 +168,32 @@ func (rc *RepoController) Update(w http.ResponseWriter, r *http.Request) {
 		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
+	// If repo does not exist
+	existRepo, err := rc.RepoInteractor.Show(repoID)
+	if err != nil {
+		rc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusInternalServerError, err)
+		return
+	}
+	if existRepo == nil {
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": "no repo found"})
+		return
+	}
 	repoName := r.PostFormValue("name")
 	repoUrl := r.PostFormValue("url")
+	// Source type is only changed when it is given
+	sourceType := r.PostFormValue("source_type")
+	if sourceType == "" {
+		sourceType = existRepo.SourceType
+	}
+	if sourceType == "" {
+		sourceType = "git"
+	}
 	repoData := &RepoData{
-		name: repoName,
-		url:  repoUrl,
+		name:       repoName,
+		url:        repoUrl,
+		sourceType: sourceType,
+		team:       r.PostFormValue("team"),
 	}
 
 	err = rc.validate(repoData)
@@ -133,24 +202,44 @@ func (rc *RepoController) Update(w http.ResponseWriter, r *http.Request) {
 		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-
-	// If repo does not exist
-	isExist, _, err := rc.exists(repoID)
+	credential, err := rc.credential(r)
 	if err != nil {
 		rc.Logger.Error(fmt.Sprintf("%s", err))
-		helper.Write(w, http.StatusInternalServerError, err)
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	if !isExist {
-		helper.Write(w, http.StatusBadRequest, map[string]string{"error": "no repo found"})
+	cloneStrategy, err := rc.cloneStrategy(r)
+	if err != nil {
+		rc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
+	maxRetries, err := rc.maxRetries(r)
+	if err != nil {
+		rc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	schedule, nextScanTime, err := rc.schedule(r)
+	if err != nil {
+		rc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	timeNow := time.Now().UTC()
 	repo := &domain.Repo{
-		ID:          repoID,
-		Name:        repoName,
-		Url:         repoUrl,
-		UpdatedTime: &timeNow,
+		ID:            repoID,
+		Name:          repoName,
+		Url:           repoUrl,
+		SourceType:    sourceType,
+		Team:          repoData.team,
+		Credential:    credential,
+		CloneStrategy: cloneStrategy,
+		MaxRetries:    maxRetries,
+		Schedule:      schedule,
+		NextScanTime:  nextScanTime,
+		UpdatedTime:   &timeNow,
 	}
 	newRepo, err := rc.RepoInteractor.Update(repo)
 	if err != nil {
@@ -205,9 +294,145 @@ func (c *RepoController) validate(rd *RepoData) error {
 	return validation.ValidateStruct(rd,
 		// Name cannot be empty, and the length must between 5 and 50
 		validation.Field(&rd.name, validation.Required, validation.Length(5, 50)),
-		// Url cannot be empty, and should be valid url
-		validation.Field(&rd.url, validation.Required, is.URL),
+		// Source type should be one of the supported sources
+		validation.Field(&rd.sourceType, validation.In("git", "local")),
+		// Team is optional, none removes it
+		validation.Field(&rd.team, validation.Length(0, 255)),
+		// Url cannot be empty, and should be valid url or a local source inside the allowed roots
+		validation.Field(&rd.url, validation.Required,
+			validation.When(rd.sourceType == "git", is.URL),
+			validation.When(rd.sourceType == "local", validation.By(validLocalSource(c.LocalRoots)))),
+	)
+}
+
+// Read the clone credential from the request, nil if it is not given
+func (rc *RepoController) credential(r *http.Request) (*domain.Credential, error) {
+	credentialType := r.PostFormValue("credential_type")
+	if credentialType == "" {
+		return nil, nil
+	}
+	credential := &domain.Credential{
+		Type:             credentialType,
+		Username:         r.PostFormValue("username"),
+		Password:         r.PostFormValue("password"),
+		PrivateKey:       r.PostFormValue("private_key"),
+		Passphrase:       r.PostFormValue("passphrase"),
+		KnownHostsPolicy: r.PostFormValue("known_hosts_policy"),
+		KnownHosts:       r.PostFormValue("known_hosts"),
+	}
+	err := validation.ValidateStruct(credential,
+		// Type should be one of the supported credentials
+		validation.Field(&credential.Type, validation.In("https", "ssh", "none")),
+		// Password or token is required for https
+		validation.Field(&credential.Password, validation.When(credential.Type == "https", validation.Required)),
+		// Private key is required for ssh, and should be a valid key
+		validation.Field(&credential.PrivateKey, validation.When(credential.Type == "ssh", validation.Required, validation.By(validPrivateKey(credential.Passphrase)))),
+		// Host keys are verified unless insecure is chosen
+		validation.Field(&credential.KnownHostsPolicy, validation.In("strict", "insecure")),
+	)
+	return credential, err
+}
+
+// Read the maximum retries of the transient scan failures from the request, nil if it is not given
+func (rc *RepoController) maxRetries(r *http.Request) (*int, error) {
+	value := r.PostFormValue("max_retries")
+	if value == "" {
+		return nil, nil
+	}
+	maxRetries, err := strconv.Atoi(value)
+	if err != nil || maxRetries < 0 {
+		return nil, errors.New("max_retries: must be a non-negative number")
+	}
+	return &maxRetries, nil
+}
+
+// Read the schedule of the recurring scans from the request, nil if it is not given. The next
+// scan time is the first time the schedule matches.
+func (rc *RepoController) schedule(r *http.Request) (*domain.Schedule, *time.Time, error) {
+	schedule := &domain.Schedule{
+		Cron:     r.PostFormValue("schedule"),
+		Timezone: r.PostFormValue("timezone"),
+	}
+	switch schedule.Cron {
+	case "":
+		return nil, nil, nil
+	// none removes the schedule on update
+	case "none":
+		return schedule, nil, nil
+	}
+	nextScanTime, err := nextScanTime(schedule, time.Now().UTC())
+	if err != nil {
+		return nil, nil, err
+	}
+	if nextScanTime == nil {
+		return nil, nil, errors.New("schedule: never matches")
+	}
+	return schedule, nextScanTime, nil
+}
+
+// Read the clone strategy from the request, nil if none of its fields is given
+func (rc *RepoController) cloneStrategy(r *http.Request) (*domain.CloneStrategy, error) {
+	r.ParseForm()
+	given := false
+	for _, field := range []string{"depth", "branch", "single_branch", "tags", "sparse_paths", "submodule_mode", "submodule_hosts"} {
+		if _, ok := r.PostForm[field]; ok {
+			given = true
+		}
+	}
+	if !given {
+		return nil, nil
+	}
+
+	strategy := &domain.CloneStrategy{
+		Branch:         r.PostFormValue("branch"),
+		Tags:           true,
+		SparsePaths:    splitList(r.PostFormValue("sparse_paths")),
+		SubmoduleMode:  r.PostFormValue("submodule_mode"),
+		SubmoduleHosts: splitList(r.PostFormValue("submodule_hosts")),
+	}
+	if strategy.SubmoduleMode == "" {
+		strategy.SubmoduleMode = "recursive"
+	}
+	var err error
+	if depth := r.PostFormValue("depth"); depth != "" {
+		if strategy.Depth, err = strconv.Atoi(depth); err != nil || strategy.Depth < 0 {
+			return nil, errors.New("depth: must be a non-negative number")
+		}
+	}
+	if singleBranch := r.PostFormValue("single_branch"); singleBranch != "" {
+		if strategy.SingleBranch, err = strconv.ParseBool(singleBranch); err != nil {
+			return nil, errors.New("single_branch: must be true or false")
+		}
+	}
+	if tags := r.PostFormValue("tags"); tags != "" {
+		if strategy.Tags, err = strconv.ParseBool(tags); err != nil {
+			return nil, errors.New("tags: must be true or false")
+		}
+	}
+	err = validation.ValidateStruct(strategy,
+		// Sparse paths should be directories inside the repo
+		validation.Field(&strategy.SparsePaths, validation.Each(validation.By(func(value interface{}) error {
+			if path, _ := value.(string); !validSparsePath(path) {
+				return errors.New("must be a relative directory")
+			}
+			return nil
+		}))),
+		// Submodule mode should be one of the supported modes
+		validation.Field(&strategy.SubmoduleMode, validation.In("none", "top-level", "recursive", "allowlist")),
+		// Allowlist mode needs the allowed hosts
+		validation.Field(&strategy.SubmoduleHosts, validation.When(strategy.SubmoduleMode == "allowlist", validation.Required)),
 	)
+	return strategy, err
+}
+
+// Split the comma separated list and drop the empty items
+func splitList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return
 }
 
 func (rc *RepoController) exists(repoID int64) (isExist bool, repoName string, err error) {
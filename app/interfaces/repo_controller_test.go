@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/scanner/app/domain"
@@ -231,3 +232,204 @@ func TestRepoControllerDeleteError(t *testing.T) {
 	repoController.Delete(rr, req)
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 }
+
+// Test Store with credential endpoint
+func TestRepoControllerStoreCredential(t *testing.T) {
+	mockRepoRepository := new(mocks.RepoRepository)
+	repoController := interfaces.RepoController{
+		RepoInteractor: usecases.RepoInteractor{
+			RepoRepository: mockRepoRepository,
+		},
+		Logger: zap.NewNop(),
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/repo", strings.NewReader("name=private&url=https://github.com/test/private&credential_type=https&password=ghp_secret"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		mockRepoRepository.On("Store", mock.MatchedBy(func(repo *domain.Repo) bool {
+			return repo.Credential != nil && repo.Credential.Password == "ghp_secret"
+		})).Return(func(repo *domain.Repo) *domain.Repo {
+			return &domain.Repo{ID: 1, Name: repo.Name, Url: repo.Url, CredentialType: "https", Credential: repo.Credential}
+		}, nil).Once()
+		repoController.Create(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"credential_type":"https"`)
+		assert.NotContains(t, rr.Body.String(), "ghp_secret")
+		mockRepoRepository.AssertExpectations(t)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/repo", strings.NewReader("name=private&url=ssh://git@github.com/test/private.git&credential_type=ssh&private_key=invalid"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		repoController.Create(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+// Test Store with clone strategy endpoint
+func TestRepoControllerStoreCloneStrategy(t *testing.T) {
+	mockRepoRepository := new(mocks.RepoRepository)
+	repoController := interfaces.RepoController{
+		RepoInteractor: usecases.RepoInteractor{
+			RepoRepository: mockRepoRepository,
+		},
+		Logger: zap.NewNop(),
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/repo", strings.NewReader("name=monorepo&url=https://github.com/test/monorepo&depth=1&tags=false&sparse_paths=src,config&submodule_mode=allowlist&submodule_hosts=github.com"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		expected := &domain.CloneStrategy{
+			Depth:          1,
+			SparsePaths:    []string{"src", "config"},
+			SubmoduleMode:  "allowlist",
+			SubmoduleHosts: []string{"github.com"},
+		}
+		mockRepoRepository.On("Store", mock.MatchedBy(func(repo *domain.Repo) bool {
+			return assert.ObjectsAreEqual(expected, repo.CloneStrategy)
+		})).Return(&domain.Repo{ID: 1, CloneStrategy: expected}, nil).Once()
+		repoController.Create(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockRepoRepository.AssertExpectations(t)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/repo", strings.NewReader("name=monorepo&url=https://github.com/test/monorepo&submodule_mode=allowlist"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		repoController.Create(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+// Test Store with the maximum retries endpoint
+func TestRepoControllerStoreMaxRetries(t *testing.T) {
+	mockRepoRepository := new(mocks.RepoRepository)
+	repoController := interfaces.RepoController{
+		RepoInteractor: usecases.RepoInteractor{
+			RepoRepository: mockRepoRepository,
+		},
+		Logger: zap.NewNop(),
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/repo", strings.NewReader("name=flakyrepo&url=https://github.com/test/flaky&max_retries=5"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		mockRepoRepository.On("Store", mock.MatchedBy(func(repo *domain.Repo) bool {
+			return repo.MaxRetries != nil && *repo.MaxRetries == 5
+		})).Return(&domain.Repo{ID: 1}, nil).Once()
+		repoController.Create(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockRepoRepository.AssertExpectations(t)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/repo", strings.NewReader("name=flakyrepo&url=https://github.com/test/flaky&max_retries=-1"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		repoController.Create(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+// Test Store with local source endpoint
+func TestRepoControllerStoreLocalSource(t *testing.T) {
+	root := t.TempDir()
+	mockRepoRepository := new(mocks.RepoRepository)
+	repoController := interfaces.RepoController{
+		RepoInteractor: usecases.RepoInteractor{
+			RepoRepository: mockRepoRepository,
+		},
+		Logger:     zap.NewNop(),
+		LocalRoots: []string{root},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/repo", strings.NewReader("name=checkout&source_type=local&url=file://"+root))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		mockRepoRepository.On("Store", mock.MatchedBy(func(repo *domain.Repo) bool {
+			return repo.SourceType == "local"
+		})).Return(&domain.Repo{ID: 1, SourceType: "local"}, nil).Once()
+		repoController.Create(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockRepoRepository.AssertExpectations(t)
+	})
+
+	t.Run("outside-roots", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/repo", strings.NewReader("name=checkout&source_type=local&url=/etc"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		repoController.Create(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+// Test Update of a local source without the source type
+func TestRepoControllerUpdateLocalSource(t *testing.T) {
+	root := t.TempDir()
+	mockRepoRepository := new(mocks.RepoRepository)
+	repoController := interfaces.RepoController{
+		RepoInteractor: usecases.RepoInteractor{
+			RepoRepository: mockRepoRepository,
+		},
+		Logger:     zap.NewNop(),
+		LocalRoots: []string{root},
+	}
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/api/repo/1", strings.NewReader("name=renamed&url=file://"+root))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("repoID", "1")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	mockRepoRepository.On("FindByID", int64(1)).Return(&domain.Repo{ID: 1, Name: "checkout", Url: "file://" + root, SourceType: "local"}, nil).Once()
+	// Stored source type is kept, so the local path is not validated as a git URL
+	mockRepoRepository.On("Update", mock.MatchedBy(func(repo *domain.Repo) bool {
+		return repo.SourceType == "local"
+	})).Return(&domain.Repo{ID: 1, Name: "renamed", SourceType: "local"}, nil).Once()
+	repoController.Update(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepoRepository.AssertExpectations(t)
+}
+
+// Test Store with the schedule endpoint
+func TestRepoControllerStoreSchedule(t *testing.T) {
+	mockRepoRepository := new(mocks.RepoRepository)
+	repoController := interfaces.RepoController{
+		RepoInteractor: usecases.RepoInteractor{
+			RepoRepository: mockRepoRepository,
+		},
+		Logger: zap.NewNop(),
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/repo", strings.NewReader("name=nightlyrepo&url=https://github.com/test/nightly&schedule=0 2 * * *&timezone=Europe/Berlin"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		mockRepoRepository.On("Store", mock.MatchedBy(func(repo *domain.Repo) bool {
+			return repo.Schedule != nil && repo.Schedule.Cron == "0 2 * * *" && repo.Schedule.Timezone == "Europe/Berlin" &&
+				repo.NextScanTime != nil && repo.NextScanTime.After(time.Now())
+		})).Return(&domain.Repo{ID: 1}, nil).Once()
+		repoController.Create(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockRepoRepository.AssertExpectations(t)
+	})
+
+	for name, body := range map[string]string{
+		"invalid":  "name=nightlyrepo&url=https://github.com/test/nightly&schedule=0 25 * * *",
+		"timezone": "name=nightlyrepo&url=https://github.com/test/nightly&schedule=@daily&timezone=Mars/Olympus",
+		"never":    "name=nightlyrepo&url=https://github.com/test/nightly&schedule=0 0 30 2 *",
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/repo", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			repoController.Create(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package interfaces
 
 import (
+	"database/sql"
+
 	"github.com/scanner/app/domain"
 )
 
 // A RepoRepository belong to the inteface layer
 type RepoRepository struct {
 	SQLHandler SQLHandler
+	// AES-256 key of the encrypted credentials
+	CredentialKey []byte
 }
 
 // FindAll returns the number of entities.
@@ -15,30 +19,54 @@ func (rr *RepoRepository) FindAll() (repos *domain.Repos, err error) {
 		SELECT
 			id,
 			name,
-			url
+			url,
+			source_type,
+			team,
+			credential_type,
+			clone_strategy,
+			max_retries,
+			schedule,
+			next_scan_time
 		FROM
 			repositories
 		WHERE status = 1
 	`
 	rows, err := rr.SQLHandler.Query(query)
-
-	defer rows.Close()
-
 	if err != nil {
 		return
 	}
+	defer rows.Close()
+
 	repos = &domain.Repos{}
 	for rows.Next() {
 		var id int64
 		var name string
 		var url string
-		if err = rows.Scan(&id, &name, &url); err != nil {
+		var sourceType sql.NullString
+		var team sql.NullString
+		var credentialType sql.NullString
+		var strategy sql.NullString
+		var maxRetries sql.NullInt64
+		var schedule sql.NullString
+		var nextScanTime sql.NullString
+		if err = rows.Scan(&id, &name, &url, &sourceType, &team, &credentialType, &strategy, &maxRetries, &schedule, &nextScanTime); err != nil {
 			return
 		}
 		repo := domain.Repo{
-			ID:   id,
-			Name: name,
-			Url:  url,
+			ID:             id,
+			Name:           name,
+			Url:            url,
+			SourceType:     sourceType.String,
+			Team:           team.String,
+			CredentialType: credentialType.String,
+			MaxRetries:     decodeMaxRetries(maxRetries),
+			NextScanTime:   decodeTime(nextScanTime),
+		}
+		if repo.CloneStrategy, err = decodeStrategy(strategy); err != nil {
+			return
+		}
+		if repo.Schedule, err = decodeSchedule(schedule); err != nil {
+			return
 		}
 		*repos = append(*repos, repo)
 	}
@@ -56,7 +84,15 @@ func (rr *RepoRepository) FindByID(repoID int64) (repo *domain.Repo, err error)
 		SELECT
 			id,
 			name,
-			url
+			url,
+			source_type,
+			team,
+			credential_type,
+			credential,
+			clone_strategy,
+			max_retries,
+			schedule,
+			next_scan_time
 		FROM
 			repositories
 		WHERE
@@ -64,26 +100,46 @@ func (rr *RepoRepository) FindByID(repoID int64) (repo *domain.Repo, err error)
 		AND status = 1
 	`
 	row, err := rr.SQLHandler.Query(query, repoID)
-
-	defer row.Close()
-
 	if err != nil {
 		return
 	}
+	defer row.Close()
 
 	var id int64
 	var name string
 	var url string
+	var sourceType sql.NullString
+	var team sql.NullString
+	var credentialType sql.NullString
+	var credential sql.NullString
+	var strategy sql.NullString
+	var maxRetries sql.NullInt64
+	var schedule sql.NullString
+	var nextScanTime sql.NullString
 	if !row.Next() {
 		return
 	}
-	if err = row.Scan(&id, &name, &url); err != nil {
+	if err = row.Scan(&id, &name, &url, &sourceType, &team, &credentialType, &credential, &strategy, &maxRetries, &schedule, &nextScanTime); err != nil {
 		return
 	}
 	repo = &domain.Repo{
-		ID:   id,
-		Name: name,
-		Url:  url,
+		ID:             id,
+		Name:           name,
+		Url:            url,
+		SourceType:     sourceType.String,
+		Team:           team.String,
+		CredentialType: credentialType.String,
+		MaxRetries:     decodeMaxRetries(maxRetries),
+		NextScanTime:   decodeTime(nextScanTime),
+	}
+	if repo.CloneStrategy, err = decodeStrategy(strategy); err != nil {
+		return
+	}
+	if repo.Schedule, err = decodeSchedule(schedule); err != nil {
+		return
+	}
+	if credential.String != "" {
+		repo.Credential, err = decryptCredential(rr.CredentialKey, credential.String)
 	}
 
 	return
@@ -95,18 +151,46 @@ func (rr *RepoRepository) Store(repo *domain.Repo) (newRepo *domain.Repo, err er
 		INSERT INTO repositories (
 			name,
 			url,
+			source_type,
+			team,
+			credential_type,
+			credential,
+			clone_strategy,
+			max_retries,
+			schedule,
+			next_scan_time,
 			created_time,
 			updated_time
 		)
 		VALUES (
+			?,
+			?,
+			?,
+			?,
+			?,
+			?,
+			?,
+			?,
 			?,
 			?,
 			?,
 			?
 		)
 	`
+	credentialType, credential, err := rr.sealCredential(repo.Credential)
+	if err != nil {
+		return
+	}
+	strategy, err := encodeStrategy(repo.CloneStrategy)
+	if err != nil {
+		return
+	}
+	schedule, err := encodeSchedule(repo.Schedule)
+	if err != nil {
+		return
+	}
 	var row Result
-	row, err = rr.SQLHandler.Exec(query, repo.Name, repo.Url, repo.CreatedTime, repo.UpdatedTime)
+	row, err = rr.SQLHandler.Exec(query, repo.Name, repo.Url, repo.SourceType, encodeTeam(repo.Team), credentialType, credential, strategy, encodeMaxRetries(repo.MaxRetries), schedule, repo.NextScanTime, repo.CreatedTime, repo.UpdatedTime)
 	if err != nil {
 		return
 	}
@@ -116,29 +200,48 @@ func (rr *RepoRepository) Store(repo *domain.Repo) (newRepo *domain.Repo, err er
 		return
 	}
 	newRepo = &domain.Repo{
-		ID:          id,
-		Name:        repo.Name,
-		Url:         repo.Url,
-		CreatedTime: repo.CreatedTime,
-		UpdatedTime: repo.UpdatedTime,
+		ID:             id,
+		Name:           repo.Name,
+		Url:            repo.Url,
+		SourceType:     repo.SourceType,
+		Team:           encodeTeam(repo.Team).String,
+		CredentialType: credentialType.String,
+		CloneStrategy:  repo.CloneStrategy,
+		MaxRetries:     repo.MaxRetries,
+		NextScanTime:   repo.NextScanTime,
+		CreatedTime:    repo.CreatedTime,
+		UpdatedTime:    repo.UpdatedTime,
+	}
+	if schedule.Valid {
+		newRepo.Schedule = repo.Schedule
 	}
 
 	return
 }
 
-// Update is to update the existing entity.
+// Update is to update the existing entity, the fields which are given are changed together.
 func (rr *RepoRepository) Update(repo *domain.Repo) (updRepo *domain.Repo, err error) {
 	query := `
 		UPDATE repositories 
 		SET
 			name = ?,
 			url = ?,
+			source_type = ?,
 			updated_time = ?
 		WHERE
 			id = ?
 	`
+	tx, err := rr.SQLHandler.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
-	_, err = rr.SQLHandler.Exec(query, repo.Name, repo.Url, repo.UpdatedTime, repo.ID)
+	_, err = tx.Exec(query, repo.Name, repo.Url, repo.SourceType, repo.UpdatedTime, repo.ID)
 	if err != nil {
 		return
 	}
@@ -146,9 +249,181 @@ func (rr *RepoRepository) Update(repo *domain.Repo) (updRepo *domain.Repo, err e
 		ID:          repo.ID,
 		Name:        repo.Name,
 		Url:         repo.Url,
+		SourceType:  repo.SourceType,
 		UpdatedTime: repo.UpdatedTime,
 	}
 
+	// Team is only changed when it is given, none removes it
+	if repo.Team != "" {
+		const teamQuery = `
+			UPDATE repositories
+			SET
+				team = ?
+			WHERE
+				id = ?
+		`
+		_, err = tx.Exec(teamQuery, encodeTeam(repo.Team), repo.ID)
+		if err != nil {
+			return
+		}
+		if repo.Team != "none" {
+			updRepo.Team = repo.Team
+		}
+	}
+
+	// Credential is only changed when it is given
+	if repo.Credential != nil {
+		const credentialQuery = `
+			UPDATE repositories
+			SET
+				credential_type = ?,
+				credential = ?
+			WHERE
+				id = ?
+		`
+		var credentialType, credential sql.NullString
+		credentialType, credential, err = rr.sealCredential(repo.Credential)
+		if err != nil {
+			return
+		}
+		_, err = tx.Exec(credentialQuery, credentialType, credential, repo.ID)
+		if err != nil {
+			return
+		}
+		updRepo.CredentialType = credentialType.String
+	}
+
+	// Clone strategy is only changed when it is given
+	if repo.CloneStrategy != nil {
+		const strategyQuery = `
+			UPDATE repositories
+			SET
+				clone_strategy = ?
+			WHERE
+				id = ?
+		`
+		var strategy sql.NullString
+		strategy, err = encodeStrategy(repo.CloneStrategy)
+		if err != nil {
+			return
+		}
+		_, err = tx.Exec(strategyQuery, strategy, repo.ID)
+		if err != nil {
+			return
+		}
+		updRepo.CloneStrategy = repo.CloneStrategy
+	}
+
+	// Maximum retries are only changed when they are given
+	if repo.MaxRetries != nil {
+		const retriesQuery = `
+			UPDATE repositories
+			SET
+				max_retries = ?
+			WHERE
+				id = ?
+		`
+		_, err = tx.Exec(retriesQuery, encodeMaxRetries(repo.MaxRetries), repo.ID)
+		if err != nil {
+			return
+		}
+		updRepo.MaxRetries = repo.MaxRetries
+	}
+
+	// Schedule is only changed when it is given, none removes it
+	if repo.Schedule != nil {
+		const scheduleQuery = `
+			UPDATE repositories
+			SET
+				schedule = ?,
+				next_scan_time = ?
+			WHERE
+				id = ?
+		`
+		var schedule sql.NullString
+		schedule, err = encodeSchedule(repo.Schedule)
+		if err != nil {
+			return
+		}
+		_, err = tx.Exec(scheduleQuery, schedule, repo.NextScanTime, repo.ID)
+		if err != nil {
+			return
+		}
+		if schedule.Valid {
+			updRepo.Schedule = repo.Schedule
+			updRepo.NextScanTime = repo.NextScanTime
+		}
+	}
+	err = tx.Commit()
+
+	return
+}
+
+// FindDue returns the scheduled entities whose next scan time has passed.
+func (rr *RepoRepository) FindDue() (repos *domain.Repos, err error) {
+	const query = `
+		SELECT
+			id,
+			url,
+			source_type,
+			team,
+			schedule
+		FROM
+			repositories
+		WHERE
+			status = 1
+		AND next_scan_time <= UTC_TIMESTAMP(6)
+		ORDER BY next_scan_time
+	`
+	rows, err := rr.SQLHandler.Query(query)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	repos = &domain.Repos{}
+	for rows.Next() {
+		var id int64
+		var url string
+		var sourceType sql.NullString
+		var team sql.NullString
+		var schedule sql.NullString
+		if err = rows.Scan(&id, &url, &sourceType, &team, &schedule); err != nil {
+			return
+		}
+		repo := domain.Repo{ID: id, Url: url, SourceType: sourceType.String, Team: team.String}
+		if repo.Schedule, err = decodeSchedule(schedule); err != nil {
+			return
+		}
+		*repos = append(*repos, repo)
+	}
+	err = rows.Err()
+
+	return
+}
+
+// Reschedule moves the due entity to its next scan time. Only one of the instances which
+// found the entity due moves it, the others get false.
+func (rr *RepoRepository) Reschedule(repo *domain.Repo) (rescheduled bool, err error) {
+	query := `
+		UPDATE repositories
+		SET
+			next_scan_time = ?
+		WHERE
+			id = ?
+		AND next_scan_time <= UTC_TIMESTAMP(6)
+	`
+	var row Result
+	row, err = rr.SQLHandler.Exec(query, repo.NextScanTime, repo.ID)
+	if err != nil {
+		return
+	}
+	affected, err := row.RowsAffected()
+	if err != nil {
+		return
+	}
+	rescheduled = affected > 0
+
 	return
 }
 
@@ -172,3 +447,42 @@ func (rr *RepoRepository) Delete(repo *domain.Repo) (updRepo *domain.Repo, err e
 
 	return
 }
+
+// Encrypt the credential for storing, none or nil credential is stored as NULL
+func (rr *RepoRepository) sealCredential(credential *domain.Credential) (credentialType, encrypted sql.NullString, err error) {
+	if credential == nil || credential.Type == "none" {
+		return
+	}
+	encrypted.String, err = encryptCredential(rr.CredentialKey, credential)
+	if err != nil {
+		return
+	}
+	encrypted.Valid = true
+	credentialType = sql.NullString{String: credential.Type, Valid: true}
+	return
+}
+
+// Maximum retries of the column, NULL uses the default of the scanner
+func decodeMaxRetries(maxRetries sql.NullInt64) *int {
+	if !maxRetries.Valid {
+		return nil
+	}
+	retries := int(maxRetries.Int64)
+	return &retries
+}
+
+// Maximum retries for the column, nil is stored as NULL
+func encodeMaxRetries(maxRetries *int) sql.NullInt64 {
+	if maxRetries == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*maxRetries), Valid: true}
+}
+
+// Team for the column, empty or none is stored as NULL
+func encodeTeam(team string) sql.NullString {
+	if team == "" || team == "none" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: team, Valid: true}
+}
@@ -0,0 +1,61 @@
+package interfaces_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/scanner/app/domain"
+	"github.com/scanner/app/domain/mocks"
+	"github.com/scanner/app/interfaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Test the update of the repo fields and its credential in one transaction
+func TestRepoRepositoryUpdate(t *testing.T) {
+	repo := &domain.Repo{ID: 2, Name: "Test", Url: "www.test.com/repo", SourceType: "git", Credential: &domain.Credential{Type: "https", Password: "token"}}
+	key := bytes.Repeat([]byte{1}, 32)
+
+	t.Run("success", func(t *testing.T) {
+		tx := new(mocks.Tx)
+		mockSQLHandler := new(mocks.SQLHandler)
+		mockSQLHandler.On("Begin").Return(tx, nil).Once()
+		tx.On("Exec", statement("name = ?"), mock.Anything).Return(nil, nil).Once()
+		tx.On("Exec", statement("credential = ?"), mock.Anything).Return(nil, nil).Once()
+		tx.On("Commit").Return(nil).Once()
+
+		updRepo, err := (&interfaces.RepoRepository{SQLHandler: mockSQLHandler, CredentialKey: key}).Update(repo)
+		assert.NoError(t, err)
+		assert.Equal(t, "https", updRepo.CredentialType)
+		tx.AssertExpectations(t)
+		tx.AssertNotCalled(t, "Rollback")
+		mockSQLHandler.AssertNotCalled(t, "Exec", mock.Anything, mock.Anything)
+	})
+
+	t.Run("error-credential", func(t *testing.T) {
+		tx := new(mocks.Tx)
+		mockSQLHandler := new(mocks.SQLHandler)
+		mockSQLHandler.On("Begin").Return(tx, nil).Once()
+		tx.On("Exec", statement("name = ?"), mock.Anything).Return(nil, nil).Once()
+		tx.On("Exec", statement("credential = ?"), mock.Anything).Return(nil, errors.New("connection lost")).Once()
+		// Fields of the repo are not changed without the credential
+		tx.On("Rollback").Return(nil).Once()
+
+		_, err := (&interfaces.RepoRepository{SQLHandler: mockSQLHandler, CredentialKey: key}).Update(repo)
+		assert.Error(t, err)
+		tx.AssertExpectations(t)
+		tx.AssertNotCalled(t, "Commit")
+	})
+}
+
+// Test the due repos when the query fails
+func TestRepoRepositoryFindDueError(t *testing.T) {
+	mockSQLHandler := new(mocks.SQLHandler)
+	mockSQLHandler.On("Query", statement("next_scan_time <= UTC_TIMESTAMP(6)"), mock.Anything).Return(nil, errors.New("connection lost")).Once()
+
+	repos, err := (&interfaces.RepoRepository{SQLHandler: mockSQLHandler}).FindDue()
+	assert.EqualError(t, err, "connection lost")
+	assert.Nil(t, repos)
+	mockSQLHandler.AssertExpectations(t)
+}
@@ -0,0 +1,77 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// Errors of the git hosts which fail the same way when the scan is retried
+var permanentErrors = []error{
+	transport.ErrRepositoryNotFound,
+	transport.ErrEmptyRemoteRepository,
+	transport.ErrAuthenticationRequired,
+	transport.ErrAuthorizationFailed,
+	transport.ErrInvalidAuthMethod,
+	context.Canceled,
+}
+
+// Classify the error of a failed scan. The network errors and the 5xx, 408 and 429 responses
+// of the git hosts are transient, every other error is permanent.
+func transientError(err error) bool {
+	for _, permanent := range permanentErrors {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+
+	// go-git reports the other status codes of the smart http protocol as unexpected errors
+	var unexpected *plumbing.UnexpectedError
+	if errors.As(err, &unexpected) {
+		var statusErr *githttp.Err
+		if errors.As(unexpected.Err, &statusErr) {
+			code := statusErr.StatusCode()
+			return code >= http.StatusInternalServerError || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+		}
+		err = unexpected.Err
+	}
+
+	// Host which does not exist is not going to be resolved by a retry
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	for _, transient := range []error{io.EOF, io.ErrUnexpectedEOF, syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.ECONNABORTED, syscall.EPIPE, syscall.ETIMEDOUT} {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// Exponential backoff of the retry, the base delay is doubled for each retry up to the maximum
+// delay. The jitter draws the delay between the half and the whole of it, so the retries of the
+// scans which failed together are spread out.
+func (sq *ScanQueue) backoff(retries int) time.Duration {
+	delay := sq.RetryMaxDelay
+	if retries < 32 {
+		if doubled := sq.RetryBaseDelay << retries; doubled > 0 && doubled < delay {
+			delay = doubled
+		}
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
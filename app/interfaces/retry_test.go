@@ -0,0 +1,65 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the classification of the scan errors
+func TestTransientError(t *testing.T) {
+	statusErr := func(code int) error {
+		request := &http.Request{URL: &url.URL{Scheme: "https", Host: "git.example.com"}}
+		return plumbing.NewUnexpectedError(&githttp.Err{Response: &http.Response{StatusCode: code, Request: request}})
+	}
+	for name, test := range map[string]struct {
+		err       error
+		transient bool
+	}{
+		"refused":       {&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		"reset":         {fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		"eof":           {io.ErrUnexpectedEOF, true},
+		"dns-temporary": {&net.DNSError{Err: "server misbehaving", IsTemporary: true}, true},
+		"dns-not-found": {&net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		"502":           {statusErr(http.StatusBadGateway), true},
+		"429":           {statusErr(http.StatusTooManyRequests), true},
+		"400":           {statusErr(http.StatusBadRequest), false},
+		"auth":          {transport.ErrAuthenticationRequired, false},
+		"not-found":     {fmt.Errorf("clone: %w", transport.ErrRepositoryNotFound), false},
+		"cancelled":     {context.Canceled, false},
+		"reference":     {plumbing.ErrReferenceNotFound, false},
+		"unknown":       {errors.New("invalid pack"), false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.transient, transientError(test.err))
+		})
+	}
+}
+
+// Test the exponential backoff with jitter
+func TestBackoff(t *testing.T) {
+	sq := &ScanQueue{RetryBaseDelay: time.Second, RetryMaxDelay: time.Minute}
+	for retries, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		delay := sq.backoff(retries)
+		assert.GreaterOrEqual(t, delay, max/2)
+		assert.LessOrEqual(t, delay, max)
+	}
+	// Delay is capped by the maximum delay
+	for _, retries := range []int{10, 40} {
+		delay := sq.backoff(retries)
+		assert.GreaterOrEqual(t, delay, 30*time.Second)
+		assert.LessOrEqual(t, delay, time.Minute)
+	}
+}
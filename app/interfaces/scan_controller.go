@@ -1,15 +1,19 @@
 package interfaces
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/scanner/app/domain"
 	"github.com/scanner/app/helper"
 	"github.com/scanner/app/usecases"
@@ -21,6 +25,40 @@ type ScanController struct {
 	ScanInteractor usecases.ScanInteractor
 	RepoInteractor usecases.RepoInteractor
 	Logger         *zap.Logger
+	// Default window in days for the certificate expiry report
+	CertExpiryWindowDays int
+	// Maximum size in bytes of an uploaded archive
+	UploadMaxSize int64
+	// Maximum size in bytes of the content of an ad-hoc content scan
+	ContentMaxSize int64
+	// "objects" scans have no worktree to blame or to follow through the history
+	ScanMode string
+	// Background workers of the repo scans
+	ScanQueue *ScanQueue
+	// Scheduler of the recurring scans of the repos
+	Scheduler *Scheduler
+	// Recovery of the scans of the dead instances
+	Recovery *Recovery
+	// Closed when the instance starts draining, the event streams end then and their clients
+	// reconnect to another instance
+	Draining <-chan struct{}
+}
+
+// Struct for the response of a queued scan
+type queuedScan struct {
+	ID        int64  `json:"id"`
+	Status    string `json:"status"`
+	StatusUrl string `json:"status_url"`
+	// Scan of the same commit and ruleset which the request was attached to
+	Reused bool   `json:"reused,omitempty"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// Struct for the JSON request of the content scan
+type contentRequest struct {
+	Content  string `json:"content"`
+	Filename string `json:"filename"`
+	Persist  bool   `json:"persist"`
 }
 
 // NewScanController returns the instance of Scan controller.
@@ -35,34 +73,300 @@ func NewScanController(sqlHandler SQLHandler, logger *zap.Logger) *ScanControlle
 		noOfWorkers = 1
 	}
 
-	return &ScanController{
-		ScanInteractor: usecases.ScanInteractor{
-			ScanRepository: &ScanRepository{
-				SQLHandler:            sqlHandler,
-				SearchPattern:         searchPatterns,
-				ScanCloneFolder:       os.Getenv("SCANClONEFOLDER"),
-				ScanCloneFolderPrefix: os.Getenv("SCANClONEFOLDERPREFIX"),
-				NoOfWorkers:           noOfWorkers,
-			},
+	// Decoding of the encoded payloads defaults to 2 levels of at most 64KB
+	decodeMaxDepth, err := strconv.Atoi(os.Getenv("DECODE_MAX_DEPTH"))
+	if err != nil || decodeMaxDepth < 0 {
+		decodeMaxDepth = 2
+	}
+	decodeMaxSize, err := strconv.Atoi(os.Getenv("DECODE_MAX_SIZE"))
+	if err != nil || decodeMaxSize <= 0 {
+		decodeMaxSize = 65536
+	}
+
+	// Keyword proximity rule, the keywords are matched case insensitive
+	var proximityKeywords []string
+	for _, keyword := range strings.Split(os.Getenv("PROXIMITY_KEYWORDS"), ",") {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			proximityKeywords = append(proximityKeywords, keyword)
+		}
+	}
+	var proximityValuePattern *regexp.Regexp
+	if pattern := os.Getenv("PROXIMITY_VALUE_PATTERN"); pattern != "" {
+		proximityValuePattern, err = regexp.Compile(pattern)
+		if err != nil {
+			logger.Error(fmt.Sprintf("invalid PROXIMITY_VALUE_PATTERN: %s", err))
+		}
+	}
+	proximityMinEntropy, err := strconv.ParseFloat(os.Getenv("PROXIMITY_MIN_ENTROPY"), 64)
+	if err != nil || proximityMinEntropy <= 0 {
+		proximityMinEntropy = 3.5
+	}
+	proximityDistance, err := strconv.Atoi(os.Getenv("PROXIMITY_DISTANCE"))
+	if err != nil || proximityDistance <= 0 {
+		proximityDistance = 40
+	}
+
+	credentialKey, err := loadCredentialKey()
+	if err != nil {
+		logger.Error(fmt.Sprintf("invalid CREDENTIAL_KEY: %s", err))
+	}
+
+	// Repos without a mirror are cloned into the memory up to 100 MiB by default, 0 clones them
+	// all into the clone folder
+	memoryMaxSize, err := strconv.ParseInt(os.Getenv("SCAN_MEMORY_MAX_SIZE"), 10, 64)
+	if err != nil || memoryMaxSize < 0 {
+		memoryMaxSize = 100 << 20
+	}
+
+	// Mirror cache is disabled when MIRROR_CACHE_FOLDER is empty
+	var mirrorCache *MirrorCache
+	if folder := os.Getenv("MIRROR_CACHE_FOLDER"); folder != "" {
+		maxSize, _ := strconv.ParseInt(os.Getenv("MIRROR_CACHE_MAX_SIZE"), 10, 64)
+		mirrorCache, err = NewMirrorCache(folder, maxSize)
+		if err != nil {
+			logger.Error(fmt.Sprintf("unable to create mirror cache: %s", err))
+		}
+	}
+
+	// If CERT_EXPIRY_WINDOW_DAYS is invalid input, we can report certificates expiring within 30 days
+	certExpiryWindowDays, err := strconv.Atoi(os.Getenv("CERT_EXPIRY_WINDOW_DAYS"))
+	if err != nil || certExpiryWindowDays < 0 {
+		certExpiryWindowDays = 30
+	}
+
+	// Uploads default to archives of 100MB which extract to at most 1GB in 100000 files
+	uploadMaxSize, err := strconv.ParseInt(os.Getenv("UPLOAD_MAX_SIZE"), 10, 64)
+	if err != nil || uploadMaxSize <= 0 {
+		uploadMaxSize = 100 << 20
+	}
+	extractMaxSize, err := strconv.ParseInt(os.Getenv("EXTRACT_MAX_SIZE"), 10, 64)
+	if err != nil || extractMaxSize <= 0 {
+		extractMaxSize = 1 << 30
+	}
+	extractMaxFiles, err := strconv.Atoi(os.Getenv("EXTRACT_MAX_FILES"))
+	if err != nil || extractMaxFiles <= 0 {
+		extractMaxFiles = 100000
+	}
+
+	// Content scans default to 1MB
+	contentMaxSize, err := strconv.ParseInt(os.Getenv("CONTENT_MAX_SIZE"), 10, 64)
+	if err != nil || contentMaxSize <= 0 {
+		contentMaxSize = 1 << 20
+	}
+
+	// Blame of the findings keeps the last 256 blamed files by default
+	blameCacheSize, err := strconv.Atoi(os.Getenv("BLAME_CACHE_SIZE"))
+	if err != nil || blameCacheSize < 0 {
+		blameCacheSize = 256
+	}
+
+	// Repo scans run on 2 workers of each instance, which hold the lease of their job for 60
+	// seconds and give up a job after 3 attempts by default
+	scanWorkers, err := strconv.Atoi(os.Getenv("SCAN_WORKERS"))
+	if err != nil || scanWorkers <= 0 {
+		scanWorkers = 2
+	}
+	scanJobLease, err := strconv.Atoi(os.Getenv("SCAN_JOB_LEASE"))
+	if err != nil || scanJobLease <= 0 {
+		scanJobLease = 60
+	}
+	scanJobMaxAttempts, err := strconv.Atoi(os.Getenv("SCAN_JOB_MAX_ATTEMPTS"))
+	if err != nil || scanJobMaxAttempts <= 0 {
+		scanJobMaxAttempts = 3
+	}
+	// Transient failures are retried 3 times unless the repo sets its own maximum, after 30
+	// seconds doubled for each retry up to 900 seconds
+	scanMaxRetries, err := strconv.Atoi(os.Getenv("SCAN_MAX_RETRIES"))
+	if err != nil || scanMaxRetries < 0 {
+		scanMaxRetries = 3
+	}
+	scanRetryBaseDelay, err := strconv.Atoi(os.Getenv("SCAN_RETRY_BASE_DELAY"))
+	if err != nil || scanRetryBaseDelay <= 0 {
+		scanRetryBaseDelay = 30
+	}
+	scanRetryMaxDelay, err := strconv.Atoi(os.Getenv("SCAN_RETRY_MAX_DELAY"))
+	if err != nil || scanRetryMaxDelay < scanRetryBaseDelay {
+		scanRetryMaxDelay = 900
+	}
+	// Scheduled repos are checked every 15 seconds by default
+	scheduleInterval, err := strconv.Atoi(os.Getenv("SCAN_SCHEDULE_INTERVAL"))
+	if err != nil || scheduleInterval <= 0 {
+		scheduleInterval = 15
+	}
+	progressInterval, err := strconv.Atoi(os.Getenv("SCAN_PROGRESS_INTERVAL"))
+	if err != nil || progressInterval <= 0 {
+		progressInterval = 1
+	}
+	// Interrupted scans are recovered every 60 seconds by default, the instances are dead after
+	// 3 intervals without a heartbeat
+	recoveryInterval, err := strconv.Atoi(os.Getenv("SCAN_RECOVERY_INTERVAL"))
+	if err != nil || recoveryInterval <= 0 {
+		recoveryInterval = 60
+	}
+
+	// Limits of the running scans of all the instances and of the file workers of the instance,
+	// 0 is unlimited
+	scanMaxConcurrent, err := strconv.Atoi(os.Getenv("SCAN_MAX_CONCURRENT"))
+	if err != nil || scanMaxConcurrent < 0 {
+		scanMaxConcurrent = 0
+	}
+	scanMaxPerHost, err := strconv.Atoi(os.Getenv("SCAN_MAX_PER_HOST"))
+	if err != nil || scanMaxPerHost < 0 {
+		scanMaxPerHost = 0
+	}
+	scanMaxFileWorkers, err := strconv.Atoi(os.Getenv("SCAN_MAX_FILE_WORKERS"))
+	if err != nil || scanMaxFileWorkers < 0 {
+		scanMaxFileWorkers = 0
+	}
+
+	// Owner of the scans and the job leases of the instance
+	owner := instanceID()
+	scanInteractor := usecases.ScanInteractor{
+		ScanRepository: &ScanRepository{
+			SQLHandler:            sqlHandler,
+			Owner:                 owner,
+			SearchPattern:         searchPatterns,
+			ScanCloneFolder:       os.Getenv("SCANClONEFOLDER"),
+			ScanCloneFolderPrefix: os.Getenv("SCANClONEFOLDERPREFIX"),
+			NoOfWorkers:           noOfWorkers,
+			FileWorkers:           NewWorkerPool(scanMaxFileWorkers),
+			MirrorCache:           mirrorCache,
+			BlameCache:            NewBlameCache(blameCacheSize),
+			ScanMode:              os.Getenv("SCAN_MODE"),
+			MemoryMaxSize:         memoryMaxSize,
+			LocalRoots:            loadLocalRoots(),
+			ExtractMaxSize:        extractMaxSize,
+			ExtractMaxFiles:       extractMaxFiles,
+			DecodeMaxDepth:        decodeMaxDepth,
+			DecodeMaxSize:         decodeMaxSize,
+			ProximityKeywords:     proximityKeywords,
+			ProximityValuePattern: proximityValuePattern,
+			ProximityMinEntropy:   proximityMinEntropy,
+			ProximityDistance:     proximityDistance,
 		},
-		RepoInteractor: usecases.RepoInteractor{
-			RepoRepository: &RepoRepository{
-				SQLHandler: sqlHandler,
-			},
+	}
+
+	repoInteractor := usecases.RepoInteractor{
+		RepoRepository: &RepoRepository{
+			SQLHandler:    sqlHandler,
+			CredentialKey: credentialKey,
+		},
+	}
+	jobInteractor := usecases.JobInteractor{
+		JobRepository: &JobRepository{
+			SQLHandler:      sqlHandler,
+			MaxScans:        scanMaxConcurrent,
+			MaxScansPerHost: scanMaxPerHost,
 		},
-		Logger: logger,
 	}
+	scanQueue := NewScanQueue(scanInteractor, repoInteractor, jobInteractor, logger)
+	scanQueue.Owner = owner
+	scanQueue.Lease = time.Duration(scanJobLease) * time.Second
+	scanQueue.MaxAttempts = scanJobMaxAttempts
+	scanQueue.MaxRetries = scanMaxRetries
+	scanQueue.RetryBaseDelay = time.Duration(scanRetryBaseDelay) * time.Second
+	scanQueue.RetryMaxDelay = time.Duration(scanRetryMaxDelay) * time.Second
+	scanQueue.Progress.Interval = time.Duration(progressInterval) * time.Second
+	// Heartbeat of the instance is renewed before its workers start
+	recovery := NewRecovery(scanInteractor, logger)
+	recovery.Interval = time.Duration(recoveryInterval) * time.Second
+	recovery.OwnerTimeout = 3 * recovery.Interval
+	recovery.Start()
+	scanQueue.Start(scanWorkers)
+	scheduler := NewScheduler(repoInteractor, scanInteractor, scanQueue, logger)
+	scheduler.Interval = time.Duration(scheduleInterval) * time.Second
+	scheduler.Start()
+
+	return &ScanController{
+		ScanInteractor:       scanInteractor,
+		RepoInteractor:       repoInteractor,
+		Logger:               logger,
+		CertExpiryWindowDays: certExpiryWindowDays,
+		UploadMaxSize:        uploadMaxSize,
+		ContentMaxSize:       contentMaxSize,
+		ScanMode:             os.Getenv("SCAN_MODE"),
+		ScanQueue:            scanQueue,
+		Scheduler:            scheduler,
+		Recovery:             recovery,
+	}
+}
+
+// Close stops the scheduler and drains the scan queue for the timeout, the scans which are still
+// running then are queued again. The heartbeat of the instance is renewed until the queue is
+// drained, so the other instances do not recover its scans meanwhile.
+func (sc *ScanController) Close(timeout time.Duration) {
+	sc.Scheduler.Close()
+	sc.ScanQueue.Drain(timeout)
+	sc.Recovery.Close()
 }
 
-// Scan the repository.
+// Scan the repository. The blame=true query parameter attributes the findings to the commits
+// which last changed their lines, timeline=true adds the exposure window of the findings. Both
+// read the history of a cloned worktree, so they are rejected for the local sources and the
+// objects scan mode.
 func (sc *ScanController) Scan(w http.ResponseWriter, r *http.Request) {
 	sc.Logger.Info(fmt.Sprintf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL))
+	options := &domain.ScanOptions{}
+	for name, option := range map[string]*bool{"blame": &options.Blame, "timeline": &options.Timeline} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		var err error
+		if *option, err = strconv.ParseBool(value); err != nil {
+			sc.Logger.Error(fmt.Sprintf("%s", err))
+			helper.Write(w, http.StatusBadRequest, map[string]string{"error": name + " must be true or false"})
+			return
+		}
+	}
+	sc.scanRepo(w, r, &domain.ScanData{Options: options}, "scan")
+}
+
+// ScanDiff scans only the lines which the head revision adds to the base revision.
+func (sc *ScanController) ScanDiff(w http.ResponseWriter, r *http.Request) {
+	sc.Logger.Info(fmt.Sprintf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL))
+	scanData := &domain.ScanData{
+		BaseRef: r.PostFormValue("base"),
+		HeadRef: r.PostFormValue("head"),
+	}
+	err := validation.ValidateStruct(scanData,
+		// Base and head revisions are required, a branch, tag or commit hash
+		validation.Field(&scanData.BaseRef, validation.Required, validation.Length(1, 255)),
+		validation.Field(&scanData.HeadRef, validation.Required, validation.Length(1, 255)),
+	)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	sc.scanRepo(w, r, scanData, "diff")
+}
+
+// Queue the scan of the repo of the request, kind is the scan or diff job of the workers. The
+// priority query parameter is low, normal or high. The request is attached to the queued or
+// running scan of the same commit and ruleset, and the successful one is returned unless the
+// force=true query parameter is given.
+func (sc *ScanController) scanRepo(w http.ResponseWriter, r *http.Request, scanData *domain.ScanData, kind string) {
 	repoID, err := strconv.ParseInt(chi.URLParam(r, "repoID"), 10, 64)
 	if err != nil {
 		sc.Logger.Error(fmt.Sprintf("%s", err))
 		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
+	priority, err := scanPriority(r.URL.Query().Get("priority"))
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	force := false
+	if value := r.URL.Query().Get("force"); value != "" {
+		if force, err = strconv.ParseBool(value); err != nil {
+			sc.Logger.Error(fmt.Sprintf("%s", err))
+			helper.Write(w, http.StatusBadRequest, map[string]string{"error": "force must be true or false"})
+			return
+		}
+	}
 
 	repo, err := sc.RepoInteractor.Show(repoID)
 	if err != nil {
@@ -79,41 +383,35 @@ func (sc *ScanController) Scan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Need to write code if repo exist or not
-	scanData := &domain.ScanData{
-		RepoID:    repoID,
-		Status:    2,
-		Result:    `{}`,
-		QueueTime: time.Now().UTC(),
-		StartTime: time.Now().UTC(),
-	}
-	scanResult, err := sc.ScanInteractor.Store(scanData)
-	if err != nil {
+	if options := scanData.Options; options != nil && (options.Blame || options.Timeline) && (repo.SourceType == "local" || sc.ScanMode == "objects") {
+		err := errors.New("blame and timeline are not supported for local sources and the objects scan mode")
 		sc.Logger.Error(fmt.Sprintf("%s", err))
-		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	updatedScanData, err := sc.ScanInteractor.Scan(repo.Url)
 
+	// Scan is queued, the workers move it through In Progress to Success or Failure
+	scanResult, reused, err := sc.ScanQueue.SubmitOnce(r.Context(), repo, scanData, kind, priority, force)
 	if err != nil {
 		sc.Logger.Error(fmt.Sprintf("%s", err))
 		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
-	// If result is empty, store empty json in db
-	if updatedScanData.Result == "" {
-		updatedScanData.Result = "{}"
+	statusUrl := fmt.Sprintf("/api/scan/result/%d", scanResult.ID)
+	w.Header().Set("Location", statusUrl)
+	if !reused {
+		helper.Write(w, http.StatusAccepted, queuedScan{ID: scanResult.ID, Status: "Queued", StatusUrl: statusUrl})
+		return
 	}
-	updatedScanData.ID = scanResult.ID
-	updatedScanData.RepoID = repoID
-	scanResult, err = sc.ScanInteractor.Update(updatedScanData)
-	if err != nil {
-		sc.Logger.Error(fmt.Sprintf("%s", err))
-		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	sc.Logger.Info(fmt.Sprintf("scan %d of commit %s is reused", scanResult.ID, scanResult.Commit))
+	response := queuedScan{ID: scanResult.ID, Status: scanResult.Status, StatusUrl: statusUrl, Reused: true, Commit: scanResult.Commit}
+	// Successful scan is returned as it is, the queued and running ones are still accepted
+	if scanResult.Status == "Success" {
+		helper.Write(w, http.StatusOK, response)
 		return
 	}
-	helper.Write(w, http.StatusOK, scanResult)
+	helper.Write(w, http.StatusAccepted, response)
 }
 
 // Index return response which contain a listing of the resource of scan results.
@@ -152,3 +450,378 @@ func (sc *ScanController) Show(w http.ResponseWriter, r *http.Request) {
 	}
 	helper.Write(w, http.StatusOK, scanResult)
 }
+
+// Events streams the progress of the scan result as Server-Sent Events until the scan is done.
+// The first event is the current state of the scan, the scans running on the other instances
+// are followed through their stored progress.
+func (sc *ScanController) Events(w http.ResponseWriter, r *http.Request) {
+	sc.Logger.Info(fmt.Sprintf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL))
+	resultID, err := strconv.ParseInt(chi.URLParam(r, "resultID"), 10, 64)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	scanResult, err := sc.ScanInteractor.Show(resultID)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if scanResult == nil {
+		err = errors.New("no result found")
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": "streaming is not supported"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Only the changes of the progress are sent
+	var last *domain.ScanProgress
+	send := func(progress domain.ScanProgress) {
+		if last != nil && *last == progress {
+			return
+		}
+		data, _ := json.Marshal(progress)
+		fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+		flusher.Flush()
+		last = &progress
+	}
+	for {
+		if updates, unsubscribe, ok := sc.ScanQueue.Progress.Subscribe(resultID); ok {
+			done := sc.follow(r, updates, send)
+			unsubscribe()
+			if done {
+				return
+			}
+		} else {
+			progress := resultProgress(scanResult)
+			send(progress)
+			if progress.Phase == phaseDone {
+				return
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-sc.Draining:
+				return
+			case <-time.After(sc.ScanQueue.Progress.Interval):
+			}
+		}
+		// Scan which ended on this instance, or which is queued or runs on another one
+		if scanResult, err = sc.ScanInteractor.Show(resultID); err != nil || scanResult == nil {
+			sc.Logger.Error(fmt.Sprintf("unable to follow scan %d: %v", resultID, err))
+			return
+		}
+	}
+}
+
+// Send the progress of the scan running on this instance until it ends, true when the scan is
+// done, the client is gone or the instance is draining
+func (sc *ScanController) follow(r *http.Request, updates <-chan domain.ScanProgress, send func(domain.ScanProgress)) bool {
+	for {
+		select {
+		case <-r.Context().Done():
+			return true
+		case <-sc.Draining:
+			return true
+		case progress, open := <-updates:
+			if !open {
+				return false
+			}
+			send(progress)
+			if progress.Phase == phaseDone {
+				return true
+			}
+		}
+	}
+}
+
+// Cancel the queued or running scan of the result. The user form value is the user who
+// cancelled it.
+func (sc *ScanController) Cancel(w http.ResponseWriter, r *http.Request) {
+	sc.Logger.Info(fmt.Sprintf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL))
+	resultID, err := strconv.ParseInt(chi.URLParam(r, "resultID"), 10, 64)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	scanData := &domain.ScanData{
+		ID:            resultID,
+		CancelledBy:   r.PostFormValue("user"),
+		CancelledTime: time.Now().UTC(),
+	}
+	err = validation.ValidateStruct(scanData,
+		validation.Field(&scanData.CancelledBy, validation.Required, validation.Length(1, 255)),
+	)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	scanResult, err := sc.ScanInteractor.Cancel(scanData)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	// Scan is finished or does not exist
+	if scanResult == nil {
+		existing, err := sc.ScanInteractor.Show(resultID)
+		if err != nil {
+			sc.Logger.Error(fmt.Sprintf("%s", err))
+			helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		status, err := http.StatusConflict, errors.New("scan is not queued or running")
+		if existing == nil {
+			status, err = http.StatusNotFound, errors.New("no result found")
+		}
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Queued job is removed, a running scan is stopped and its clone is deleted
+	if err = sc.ScanQueue.Cancel(resultID); err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	helper.Write(w, http.StatusOK, scanResult)
+}
+
+// Certificates return response which contain the certificates of the repo expiring within the window.
+func (sc *ScanController) Certificates(w http.ResponseWriter, r *http.Request) {
+	sc.Logger.Info(fmt.Sprintf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL))
+	repoID, err := strconv.ParseInt(chi.URLParam(r, "repoID"), 10, 64)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	windowDays := sc.CertExpiryWindowDays
+	if window := r.URL.Query().Get("window"); window != "" {
+		windowDays, err = strconv.Atoi(window)
+		if err != nil || windowDays < 0 {
+			err = errors.New("window must be a non-negative number of days")
+			sc.Logger.Error(fmt.Sprintf("%s", err))
+			helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	report, err := sc.ScanInteractor.Certificates(repoID, time.Duration(windowDays)*24*time.Hour)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// If the repo has no successful scan yet
+	if report == nil {
+		err = errors.New("no result found")
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	helper.Write(w, http.StatusOK, report)
+}
+
+// Upload scans the uploaded tar.gz or zip archive while the request lasts. The archive is not
+// kept, so a scan stopped with the request fails, and a cancelled one stays Cancelled.
+func (sc *ScanController) Upload(w http.ResponseWriter, r *http.Request) {
+	sc.Logger.Info(fmt.Sprintf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL))
+	r.Body = http.MaxBytesReader(w, r.Body, sc.UploadMaxSize)
+	file, header, err := r.FormFile("archive")
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	format := archiveFormat(header.Filename)
+	if format == "" {
+		err = errors.New("archive must be a .tar.gz, .tgz or .zip file")
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	timeNow := time.Now().UTC()
+	upload, err := sc.ScanInteractor.StoreUpload(&domain.Upload{
+		Name:        header.Filename,
+		Format:      format,
+		Size:        header.Size,
+		CreatedTime: &timeNow,
+	})
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	scanData := &domain.ScanData{
+		UploadID:  upload.ID,
+		Status:    2,
+		Result:    `{}`,
+		QueueTime: timeNow,
+		StartTime: timeNow,
+	}
+	scanResult, err := sc.ScanInteractor.Store(scanData)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	// Scan ends with the request, e.g. when the client disconnects or the request times out, and
+	// it is tracked like the scans of the workers, so the cancel endpoint and the drain stop it
+	ctx, _ := sc.ScanQueue.track(r.Context(), scanResult.ID)
+	updatedScanData, err := sc.ScanInteractor.ScanArchive(ctx, upload, file)
+	cancelled := ctx.Err() != nil && r.Context().Err() == nil && !sc.ScanQueue.isDrained()
+	sc.ScanQueue.untrack(scanResult.ID)
+	// Result of the cancelled scan is already stored
+	if cancelled {
+		err = errors.New("scan cancelled")
+		sc.Logger.Info(fmt.Sprintf("scan %d cancelled", scanResult.ID))
+		helper.Write(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		// The archive is not kept, so the scan can not be retried
+		sc.ScanInteractor.Update(&domain.ScanData{
+			ID:       scanResult.ID,
+			UploadID: upload.ID,
+			Status:   4,
+			Result:   "{}",
+			EndTime:  time.Now().UTC(),
+		})
+		// Archives which can not be extracted safely are rejected
+		status := http.StatusInternalServerError
+		if errors.Is(err, errInvalidArchive) {
+			status = http.StatusBadRequest
+		}
+		helper.Write(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// If result is empty, store empty json in db
+	if updatedScanData.Result == "" {
+		updatedScanData.Result = "{}"
+	}
+	updatedScanData.ID = scanResult.ID
+	updatedScanData.UploadID = upload.ID
+	scanResult, err = sc.ScanInteractor.Update(updatedScanData)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	scanResult.UploadID = upload.ID
+	helper.Write(w, http.StatusOK, scanResult)
+}
+
+// Content scans the raw text or the JSON content of the request without cloning any repo.
+// The findings have the same fields as the repository scans, so the content itself is never
+// returned or logged. The result is only stored when persist is set.
+func (sc *ScanController) Content(w http.ResponseWriter, r *http.Request) {
+	sc.Logger.Info(fmt.Sprintf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL))
+	request, err := sc.contentRequest(w, r)
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	queueTime := time.Now().UTC()
+	scanData, err := sc.ScanInteractor.ScanContent(request.Filename, []byte(request.Content))
+	if err != nil {
+		sc.Logger.Error(fmt.Sprintf("%s", err))
+		helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	response := struct {
+		ResultID int64           `json:"result_id,omitempty"`
+		Result   json.RawMessage `json:"result"`
+	}{Result: json.RawMessage(scanData.Result)}
+	if request.Persist {
+		if response.ResultID, err = sc.persistContent(request, scanData, queueTime); err != nil {
+			sc.Logger.Error(fmt.Sprintf("%s", err))
+			helper.Write(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	helper.Write(w, http.StatusOK, response)
+}
+
+// Read the content scan request from the JSON or the raw text body within the size limit
+func (sc *ScanController) contentRequest(w http.ResponseWriter, r *http.Request) (request *contentRequest, err error) {
+	r.Body = http.MaxBytesReader(w, r.Body, sc.ContentMaxSize)
+	request = &contentRequest{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err = json.NewDecoder(r.Body).Decode(request); err != nil {
+			return
+		}
+	} else {
+		var content []byte
+		if content, err = io.ReadAll(r.Body); err != nil {
+			return
+		}
+		request.Content = string(content)
+		request.Filename = r.URL.Query().Get("filename")
+		request.Persist, _ = strconv.ParseBool(r.URL.Query().Get("persist"))
+	}
+	if request.Filename == "" {
+		request.Filename = "content"
+	}
+	err = validation.ValidateStruct(request,
+		// Content cannot be empty
+		validation.Field(&request.Content, validation.Required),
+		// Filename is stored as the name of the ad-hoc source
+		validation.Field(&request.Filename, validation.Length(1, 255)),
+	)
+	return
+}
+
+// Store the result of the content scan, linked to an ad-hoc content source
+func (sc *ScanController) persistContent(request *contentRequest, scanData *domain.ScanData, queueTime time.Time) (resultID int64, err error) {
+	upload, err := sc.ScanInteractor.StoreUpload(&domain.Upload{
+		Name:        request.Filename,
+		Format:      "content",
+		Size:        int64(len(request.Content)),
+		CreatedTime: &queueTime,
+	})
+	if err != nil {
+		return
+	}
+	scanResult, err := sc.ScanInteractor.Store(&domain.ScanData{
+		UploadID:  upload.ID,
+		Status:    2,
+		Result:    `{}`,
+		QueueTime: queueTime,
+		StartTime: queueTime,
+	})
+	if err != nil {
+		return
+	}
+	scanData.ID = scanResult.ID
+	scanData.UploadID = upload.ID
+	if _, err = sc.ScanInteractor.Update(scanData); err != nil {
+		return
+	}
+	return scanResult.ID, nil
+}
@@ -0,0 +1,492 @@
+package interfaces
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/scanner/app/domain"
+	"github.com/scanner/app/usecases"
+	"go.uber.org/zap"
+)
+
+// A ScanQueue runs the queued scans on a pool of background workers. The jobs are kept in the
+// database, so the workers of every instance share the queue and a job is run by one worker at
+// a time. A worker holds the lease of its job while it runs, and the job of a crashed worker is
+// claimed again when the lease expires. A scan stops when its job is removed from the queue.
+type ScanQueue struct {
+	ScanInteractor usecases.ScanInteractor
+	RepoInteractor usecases.RepoInteractor
+	JobInteractor  usecases.JobInteractor
+	Logger         *zap.Logger
+	// Owner of the leases of this instance
+	Owner string
+	// Lease of a claimed job, it is renewed every third of the lease while the scan runs
+	Lease time.Duration
+	// Wait of an idle worker before it looks for a job again
+	PollInterval time.Duration
+	// Claims of a job before it is failed, a claim is only repeated when a worker stopped
+	MaxAttempts int
+	// Retries of the transient failures of the repos which have no maximum of their own
+	MaxRetries int
+	// Delay of the first retry, which is doubled for each retry up to the maximum delay
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// Progress of the scans running on the workers of the instance
+	Progress *ProgressHub
+	// Wait of the drain for the scans it stopped, the scans which still run then are queued
+	// again by the recovery of the other instances
+	StopGrace time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	// Cancel functions of the scans running on the workers of the instance
+	mu      sync.Mutex
+	running map[int64]context.CancelFunc
+	// Running scans were stopped by the drain of the instance
+	drained bool
+}
+
+// NewScanQueue returns the scan queue of the instance with the default lease of 1 minute.
+func NewScanQueue(scanInteractor usecases.ScanInteractor, repoInteractor usecases.RepoInteractor, jobInteractor usecases.JobInteractor, logger *zap.Logger) *ScanQueue {
+	return &ScanQueue{
+		ScanInteractor: scanInteractor,
+		RepoInteractor: repoInteractor,
+		JobInteractor:  jobInteractor,
+		Logger:         logger,
+		Owner:          instanceID(),
+		Lease:          time.Minute,
+		PollInterval:   time.Second,
+		MaxAttempts:    3,
+		MaxRetries:     3,
+		RetryBaseDelay: 30 * time.Second,
+		RetryMaxDelay:  15 * time.Minute,
+		Progress:       NewProgressHub(scanInteractor, logger),
+		StopGrace:      5 * time.Second,
+		stop:           make(chan struct{}),
+		running:        make(map[int64]context.CancelFunc),
+	}
+}
+
+// Unique owner of the leases of the instance
+func instanceID() string {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Start the workers of the queue
+func (sq *ScanQueue) Start(workers int) {
+	for w := 1; w <= workers; w++ {
+		sq.wg.Add(1)
+		go sq.worker()
+	}
+}
+
+// Enqueue the job of the stored scan row of the repo, kind is scan or diff
+func (sq *ScanQueue) Enqueue(repo *domain.Repo, scanData *domain.ScanData, kind string, priority int) error {
+	job := &domain.ScanJob{
+		ResultID: scanData.ID,
+		RepoID:   scanData.RepoID,
+		Kind:     kind,
+		Priority: priority,
+		Team:     repo.Team,
+	}
+	if repo.SourceType != "local" {
+		job.Host = gitHost(repo.Url)
+	}
+	_, err := sq.JobInteractor.Store(job)
+	return err
+}
+
+// Submit stores the queued scan row of the repo and enqueues its job, the row is failed when the
+// job cannot be queued.
+func (sq *ScanQueue) Submit(repo *domain.Repo, scanData *domain.ScanData, kind string, priority int) (scanResult *domain.ScanResult, err error) {
+	scanData.RepoID = repo.ID
+	scanData.Status = 1
+	scanData.Result = `{}`
+	scanData.QueueTime = time.Now().UTC()
+	if scanResult, err = sq.ScanInteractor.Store(scanData); err != nil {
+		return
+	}
+	scanData.ID = scanResult.ID
+	err = sq.enqueueStored(repo, scanData, kind, priority)
+	return
+}
+
+// SubmitOnce submits the scan of the repo unless the same scan is queued or running, or finished
+// successfully when force is not set. The same scan reads the same commit with the same ruleset,
+// options and clone strategy, the existing scan row is returned as reused then. The scan is
+// submitted as it is when its commit cannot be resolved.
+func (sq *ScanQueue) SubmitOnce(ctx context.Context, repo *domain.Repo, scanData *domain.ScanData, kind string, priority int, force bool) (scanResult *domain.ScanResult, reused bool, err error) {
+	commit, err := sq.ScanInteractor.Resolve(ctx, repo, scanData.BaseRef, scanData.HeadRef)
+	if err != nil {
+		sq.Logger.Error(fmt.Sprintf("unable to resolve the commit of repo %d: %s", repo.ID, err))
+	}
+	if commit == "" {
+		scanResult, err = sq.Submit(repo, scanData, kind, priority)
+		return
+	}
+
+	scanData.RepoID = repo.ID
+	scanData.Status = 1
+	scanData.Result = `{}`
+	scanData.QueueTime = time.Now().UTC()
+	scanData.Commit = commit
+	scanData.Ruleset = sq.ScanInteractor.Ruleset()
+	scanData.ScanKey = scanKey(repo, kind, scanData)
+	if scanResult, reused, err = sq.ScanInteractor.StoreOnce(scanData, !force); err != nil || reused {
+		return
+	}
+	scanData.ID = scanResult.ID
+	err = sq.enqueueStored(repo, scanData, kind, priority)
+	return
+}
+
+// Enqueue the job of the stored scan row, the row is failed when the job cannot be queued
+func (sq *ScanQueue) enqueueStored(repo *domain.Repo, scanData *domain.ScanData, kind string, priority int) error {
+	err := sq.Enqueue(repo, scanData, kind, priority)
+	if err != nil {
+		if _, updateErr := sq.ScanInteractor.Update(failedScan(scanData, err)); updateErr != nil {
+			sq.Logger.Error(fmt.Sprintf("unable to update scan %d: %s", scanData.ID, updateErr))
+		}
+	}
+	return err
+}
+
+// Cancel removes the jobs of the scan result from the queue. The scan stops at once when it
+// runs on this instance, the other instances stop it when they fail to renew the lease.
+func (sq *ScanQueue) Cancel(resultID int64) error {
+	if err := sq.JobInteractor.Remove(resultID); err != nil {
+		return err
+	}
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	if cancel, ok := sq.running[resultID]; ok {
+		cancel()
+	}
+	return nil
+}
+
+// Close stops the workers and waits until their running scans are finished.
+func (sq *ScanQueue) Close() {
+	sq.stopOnce.Do(func() { close(sq.stop) })
+	sq.wg.Wait()
+}
+
+// Drain stops the workers and waits for their running scans until the stop grace before the
+// timeout. The scans which are still running then are stopped and queued again, so the workers
+// of the other instances run them. The drain returns after the stop grace even when a scan has
+// not stopped, so it ends within the timeout, or the stop grace when the timeout is shorter.
+func (sq *ScanQueue) Drain(timeout time.Duration) {
+	sq.stopOnce.Do(func() { close(sq.stop) })
+	finished := make(chan struct{})
+	go func() {
+		sq.wg.Wait()
+		close(finished)
+	}()
+	wait := timeout - sq.StopGrace
+	if wait < 0 {
+		wait = 0
+	}
+	select {
+	case <-finished:
+		return
+	case <-time.After(wait):
+	}
+	sq.mu.Lock()
+	sq.drained = true
+	for _, cancel := range sq.running {
+		cancel()
+	}
+	sq.mu.Unlock()
+	select {
+	case <-finished:
+	case <-time.After(sq.StopGrace):
+		sq.Logger.Error("scans did not stop within the grace of the drain, they are recovered by the other instances")
+	}
+}
+
+// Put the job of the scan stopped by the drain back on the queue, false when it was removed
+func (sq *ScanQueue) release(job *domain.ScanJob) bool {
+	if err := sq.JobInteractor.Release(job); err != nil {
+		if err != errLeaseLost {
+			sq.Logger.Error(fmt.Sprintf("unable to release scan job %d: %s", job.ID, err))
+		}
+		return false
+	}
+	if err := sq.ScanInteractor.Requeue(job.ResultID); err != nil {
+		sq.Logger.Error(fmt.Sprintf("unable to queue scan %d again: %s", job.ResultID, err))
+	}
+	return true
+}
+
+// Whether the running scans were stopped by the drain
+func (sq *ScanQueue) isDrained() bool {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	return sq.drained
+}
+
+// Each worker claims the jobs one by one, it waits for the poll interval when there is none
+func (sq *ScanQueue) worker() {
+	defer sq.wg.Done()
+	for {
+		select {
+		case <-sq.stop:
+			return
+		default:
+		}
+		job, err := sq.JobInteractor.Claim(sq.Owner, sq.Lease)
+		if err != nil {
+			sq.Logger.Error(fmt.Sprintf("unable to claim scan job: %s", err))
+		}
+		if job == nil {
+			select {
+			case <-sq.stop:
+				return
+			case <-time.After(sq.PollInterval):
+			}
+			continue
+		}
+		sq.run(job)
+	}
+}
+
+// Move the scan of the job through In Progress to Success or Failure, a cancelled scan is
+// left as it is. Transient failures are queued again with a backoff, and every attempt is
+// recorded with its error.
+func (sq *ScanQueue) run(job *domain.ScanJob) {
+	scanData := &domain.ScanData{ID: job.ResultID, RepoID: job.RepoID}
+	scanResult, err := sq.ScanInteractor.Show(job.ResultID)
+	if err == nil && scanResult == nil {
+		err = errors.New("scan result not found")
+	}
+	if err != nil {
+		sq.Logger.Error(fmt.Sprintf("scan job %d: %s", job.ID, err))
+		sq.complete(job)
+		return
+	}
+	scanData.BaseRef = scanResult.BaseRef
+	scanData.HeadRef = scanResult.HeadRef
+	scanData.Options = scanResult.Options
+	scanData.Commit = scanResult.Commit
+
+	var updatedScanData *domain.ScanData
+	attempt := &domain.AttemptData{ResultID: scanData.ID, Attempt: job.Retries + 1}
+	finish := func(phase, status string) {}
+	if job.Attempts > sq.MaxAttempts {
+		// Workers which ran the job stopped before finishing it
+		err = fmt.Errorf("scan abandoned after %d attempts", job.Attempts-1)
+		updatedScanData = failedScan(scanData, err)
+		attempt.StartTime = updatedScanData.EndTime
+		attempt.EndTime = updatedScanData.EndTime
+		attempt.Error = err.Error()
+	} else {
+		scanData.Status = 2
+		scanData.StartTime = time.Now().UTC()
+		if _, err := sq.ScanInteractor.Start(scanData); err != nil {
+			sq.Logger.Error(fmt.Sprintf("unable to start scan %d: %s", scanData.ID, err))
+		}
+
+		ctx, cancel := sq.track(context.Background(), job.ResultID)
+		ctx, finish = sq.Progress.track(ctx, job.ResultID)
+		stopHeartbeat, leaseLost := sq.heartbeat(job, cancel)
+		updatedScanData, err = sq.scan(ctx, job, scanData)
+		stopHeartbeat()
+		cancelled := ctx.Err() != nil
+		sq.untrack(job.ResultID)
+		// Worker which took over the lease runs the scan again and stores its result, a job
+		// which was removed is cancelled
+		if leaseLost() {
+			sq.Logger.Error(fmt.Sprintf("scan job %d: %s", job.ID, errLeaseLost))
+			finish("", "")
+			return
+		}
+		// Scan stopped by the drain is queued again, unless it was cancelled as well
+		if cancelled && sq.isDrained() && sq.release(job) {
+			sq.Logger.Info(fmt.Sprintf("scan %d is queued again", scanData.ID))
+			finish(phaseQueued, "")
+			return
+		}
+		if cancelled {
+			sq.Logger.Info(fmt.Sprintf("scan %d cancelled", scanData.ID))
+			finish(phaseDone, "Cancelled")
+			return
+		}
+		progressFrom(ctx).setPhase(phaseStoring)
+		attempt.StartTime = scanData.StartTime
+		attempt.EndTime = time.Now().UTC()
+		if err != nil {
+			sq.Logger.Error(fmt.Sprintf("scan %d failed: %s", scanData.ID, err))
+			attempt.Error = err.Error()
+			attempt.Transient = transientError(err)
+			if attempt.Transient && job.Retries < sq.maxRetries(job.RepoID) {
+				delay := sq.backoff(job.Retries)
+				attempt.RetryTime = attempt.EndTime.Add(delay)
+				sq.storeAttempt(attempt)
+				if err = sq.JobInteractor.Retry(job, delay); err != nil {
+					sq.Logger.Error(fmt.Sprintf("unable to retry scan job %d: %s", job.ID, err))
+				} else if err = sq.ScanInteractor.Requeue(job.ResultID); err != nil {
+					sq.Logger.Error(fmt.Sprintf("unable to queue scan %d again: %s", job.ResultID, err))
+				}
+				finish(phaseQueued, "")
+				return
+			}
+			updatedScanData = failedScan(scanData, err)
+		}
+	}
+	sq.storeAttempt(attempt)
+
+	// If result is empty, store empty json in db
+	if updatedScanData.Result == "" {
+		updatedScanData.Result = "{}"
+	}
+	updatedScanData.ID = scanData.ID
+	updatedScanData.RepoID = scanData.RepoID
+	_, err = sq.ScanInteractor.Update(updatedScanData)
+	finish(phaseDone, statusText(updatedScanData.Status))
+	if err != nil {
+		sq.Logger.Error(fmt.Sprintf("unable to update scan %d: %s", scanData.ID, err))
+		return
+	}
+	sq.complete(job)
+}
+
+// Scan the repo of the job until the context is cancelled
+func (sq *ScanQueue) scan(ctx context.Context, job *domain.ScanJob, scanData *domain.ScanData) (*domain.ScanData, error) {
+	repo, err := sq.RepoInteractor.Show(job.RepoID)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return nil, errors.New("no repos found")
+	}
+	var scanned *domain.ScanData
+	if job.Kind == "diff" {
+		scanned, err = sq.ScanInteractor.ScanDiff(ctx, repo, scanData.BaseRef, scanData.HeadRef)
+	} else {
+		scanned, err = sq.ScanInteractor.Scan(ctx, repo, scanData.Options)
+	}
+	// Branch may have moved since the commit was resolved, the result is keyed by the commit
+	// and the rules which were actually scanned, so it is reused by the scans of them
+	if err == nil && scanData.Commit != "" && scanned.Commit != "" {
+		scanned.Ruleset = sq.ScanInteractor.Ruleset()
+		scanned.ScanKey = scanKey(repo, job.Kind, &domain.ScanData{Commit: scanned.Commit, Ruleset: scanned.Ruleset, Options: scanData.Options})
+	}
+	return scanned, err
+}
+
+// Maximum retries of the repo, the default of the queue when the repo has none
+func (sq *ScanQueue) maxRetries(repoID int64) int {
+	repo, err := sq.RepoInteractor.Show(repoID)
+	if err != nil {
+		sq.Logger.Error(fmt.Sprintf("unable to read the maximum retries of repo %d: %s", repoID, err))
+	}
+	if repo == nil || repo.MaxRetries == nil {
+		return sq.MaxRetries
+	}
+	return *repo.MaxRetries
+}
+
+// Record the attempt of the scan, the scan goes on when it cannot be recorded
+func (sq *ScanQueue) storeAttempt(attempt *domain.AttemptData) {
+	if err := sq.ScanInteractor.StoreAttempt(attempt); err != nil {
+		sq.Logger.Error(fmt.Sprintf("unable to record attempt %d of scan %d: %s", attempt.Attempt, attempt.ResultID, err))
+	}
+}
+
+// Renew the lease of the job until the returned function stops it. The other function tells
+// whether the lease was taken over by another worker, the scan is cancelled when it was.
+func (sq *ScanQueue) heartbeat(job *domain.ScanJob, cancel context.CancelFunc) (stop func(), lost func() bool) {
+	var (
+		mu       sync.Mutex
+		leaseErr error
+	)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(sq.Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := sq.JobInteractor.Renew(job, sq.Lease)
+				if err == nil {
+					continue
+				}
+				sq.Logger.Error(fmt.Sprintf("unable to renew the lease of scan job %d: %s", job.ID, err))
+				if err == errLeaseLost {
+					mu.Lock()
+					leaseErr = err
+					mu.Unlock()
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	stop = func() {
+		close(done)
+		<-stopped
+	}
+	lost = func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return leaseErr != nil
+	}
+	return
+}
+
+// Context of the running scan of the result, which Cancel cancels
+func (sq *ScanQueue) track(parent context.Context, resultID int64) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	sq.running[resultID] = cancel
+	return ctx, cancel
+}
+
+// Release the context of the finished scan of the result
+func (sq *ScanQueue) untrack(resultID int64) {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	if cancel, ok := sq.running[resultID]; ok {
+		cancel()
+		delete(sq.running, resultID)
+	}
+}
+
+// Remove the finished job from the queue
+func (sq *ScanQueue) complete(job *domain.ScanJob) {
+	if err := sq.JobInteractor.Complete(job); err != nil {
+		sq.Logger.Error(fmt.Sprintf("unable to complete scan job %d: %s", job.ID, err))
+	}
+}
+
+// Failed scan of the stored row, the error is kept in the result
+func failedScan(scanData *domain.ScanData, scanErr error) *domain.ScanData {
+	result, _ := json.Marshal(map[string]string{"error": scanErr.Error()})
+	return &domain.ScanData{
+		ID:            scanData.ID,
+		RepoID:        scanData.RepoID,
+		BaseRef:       scanData.BaseRef,
+		HeadRef:       scanData.HeadRef,
+		Options:       scanData.Options,
+		CloneStrategy: scanData.CloneStrategy,
+		Status:        4,
+		Result:        string(result),
+		EndTime:       time.Now().UTC(),
+	}
+}
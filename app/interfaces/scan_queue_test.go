@@ -0,0 +1,388 @@
+package interfaces_test
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/scanner/app/domain"
+	"github.com/scanner/app/domain/mocks"
+	"github.com/scanner/app/interfaces"
+	"github.com/scanner/app/usecases"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// Run the queue until the job is completed
+func runScanQueue(t *testing.T, job *domain.ScanJob, mockScanRepository *mocks.ScanRepository, mockRepoRepository *mocks.RepoRepository, mockJobRepository *mocks.JobRepository) {
+	completed := make(chan struct{})
+	mockScanRepository.On("StoreProgress", job.ResultID, mock.AnythingOfType("*domain.ScanProgress")).Return(nil).Maybe()
+	mockJobRepository.On("Claim", mock.Anything, time.Minute).Return(job, nil).Once()
+	mockJobRepository.On("Claim", mock.Anything, time.Minute).Return(nil, nil).Maybe()
+	mockJobRepository.On("Complete", job).Return(nil).Run(func(mock.Arguments) {
+		close(completed)
+	}).Once()
+
+	scanQueue := interfaces.NewScanQueue(
+		usecases.ScanInteractor{ScanRepository: mockScanRepository},
+		usecases.RepoInteractor{RepoRepository: mockRepoRepository},
+		usecases.JobInteractor{JobRepository: mockJobRepository},
+		zap.NewNop(),
+	)
+	scanQueue.PollInterval = 10 * time.Millisecond
+	scanQueue.Start(1)
+	select {
+	case <-completed:
+	case <-time.After(5 * time.Second):
+		t.Error("job was not completed")
+	}
+	scanQueue.Close()
+}
+
+// Test the scans of the queued jobs
+func TestScanQueue(t *testing.T) {
+	repo := &domain.Repo{ID: 1, Url: "www.test.com/repo"}
+
+	t.Run("success", func(t *testing.T) {
+		mockScanRepository := new(mocks.ScanRepository)
+		mockRepoRepository := new(mocks.RepoRepository)
+		mockJobRepository := new(mocks.JobRepository)
+		options := &domain.ScanOptions{Blame: true}
+		mockScanRepository.On("FindByID", int64(4)).Return(&domain.ScanResult{ID: 4, Status: "Queued", Options: options}, nil).Once()
+		mockScanRepository.On("Start", mock.MatchedBy(func(data *domain.ScanData) bool {
+			return data.ID == 4 && data.Status == 2 && !data.StartTime.IsZero()
+		})).Return(&domain.ScanResult{ID: 4}, nil).Once()
+		mockRepoRepository.On("FindByID", int64(1)).Return(repo, nil).Once()
+		mockScanRepository.On("Scan", mock.Anything, repo, options).Return(&domain.ScanData{Status: 3, EndTime: time.Now().UTC()}, nil).Once()
+		mockScanRepository.On("Update", mock.MatchedBy(func(data *domain.ScanData) bool {
+			return data.ID == 4 && data.RepoID == 1 && data.Status == 3 && data.Result == "{}"
+		})).Return(&domain.ScanResult{ID: 4, Status: "Success"}, nil).Once()
+		mockScanRepository.On("StoreAttempt", mock.MatchedBy(func(attempt *domain.AttemptData) bool {
+			return attempt.ResultID == 4 && attempt.Attempt == 1 && attempt.Error == "" && !attempt.StartTime.IsZero()
+		})).Return(nil).Once()
+
+		runScanQueue(t, &domain.ScanJob{ID: 9, ResultID: 4, RepoID: 1, Kind: "scan", Attempts: 1}, mockScanRepository, mockRepoRepository, mockJobRepository)
+		mockScanRepository.AssertExpectations(t)
+		mockRepoRepository.AssertExpectations(t)
+		mockJobRepository.AssertExpectations(t)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		mockScanRepository := new(mocks.ScanRepository)
+		mockRepoRepository := new(mocks.RepoRepository)
+		mockJobRepository := new(mocks.JobRepository)
+		mockScanRepository.On("FindByID", int64(5)).Return(&domain.ScanResult{ID: 5, BaseRef: "main", HeadRef: "feature"}, nil).Once()
+		mockScanRepository.On("Start", mock.AnythingOfType("*domain.ScanData")).Return(&domain.ScanResult{ID: 5}, nil).Once()
+		mockRepoRepository.On("FindByID", int64(1)).Return(repo, nil).Once()
+		mockScanRepository.On("ScanDiff", mock.Anything, repo, "main", "feature").Return(&domain.ScanData{Status: 3}, nil).Once()
+		mockScanRepository.On("Update", mock.MatchedBy(func(data *domain.ScanData) bool {
+			return data.ID == 5 && data.Status == 3
+		})).Return(&domain.ScanResult{ID: 5, Status: "Success"}, nil).Once()
+		mockScanRepository.On("StoreAttempt", mock.AnythingOfType("*domain.AttemptData")).Return(nil).Once()
+
+		runScanQueue(t, &domain.ScanJob{ID: 10, ResultID: 5, RepoID: 1, Kind: "diff", Attempts: 1}, mockScanRepository, mockRepoRepository, mockJobRepository)
+		mockScanRepository.AssertExpectations(t)
+	})
+
+	t.Run("moved", func(t *testing.T) {
+		mockScanRepository := new(mocks.ScanRepository)
+		mockRepoRepository := new(mocks.RepoRepository)
+		mockJobRepository := new(mocks.JobRepository)
+		mockScanRepository.On("FindByID", int64(7)).Return(&domain.ScanResult{ID: 7, Commit: "1111111111111111111111111111111111111111", Ruleset: "old"}, nil).Once()
+		mockScanRepository.On("Start", mock.AnythingOfType("*domain.ScanData")).Return(&domain.ScanResult{ID: 7}, nil).Once()
+		mockRepoRepository.On("FindByID", int64(1)).Return(repo, nil).Once()
+		// Branch moved after the commit was resolved
+		mockScanRepository.On("Scan", mock.Anything, repo, (*domain.ScanOptions)(nil)).Return(&domain.ScanData{Status: 3, Commit: "2222222222222222222222222222222222222222"}, nil).Once()
+		mockScanRepository.On("Ruleset").Return("new").Once()
+		mockScanRepository.On("Update", mock.MatchedBy(func(data *domain.ScanData) bool {
+			return data.ID == 7 && data.Commit == "2222222222222222222222222222222222222222" && data.Ruleset == "new" && len(data.ScanKey) == 64
+		})).Return(&domain.ScanResult{ID: 7, Status: "Success"}, nil).Once()
+		mockScanRepository.On("StoreAttempt", mock.AnythingOfType("*domain.AttemptData")).Return(nil).Once()
+
+		runScanQueue(t, &domain.ScanJob{ID: 14, ResultID: 7, RepoID: 1, Kind: "scan", Attempts: 1}, mockScanRepository, mockRepoRepository, mockJobRepository)
+		mockScanRepository.AssertExpectations(t)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		mockScanRepository := new(mocks.ScanRepository)
+		mockRepoRepository := new(mocks.RepoRepository)
+		mockJobRepository := new(mocks.JobRepository)
+		mockScanRepository.On("FindByID", int64(6)).Return(&domain.ScanResult{ID: 6}, nil).Once()
+		mockScanRepository.On("Start", mock.AnythingOfType("*domain.ScanData")).Return(&domain.ScanResult{ID: 6}, nil).Once()
+		mockRepoRepository.On("FindByID", int64(1)).Return(repo, nil).Once()
+		mockScanRepository.On("Scan", mock.Anything, repo, (*domain.ScanOptions)(nil)).Return(nil, transport.ErrAuthenticationRequired).Once()
+		mockScanRepository.On("Update", mock.MatchedBy(func(data *domain.ScanData) bool {
+			return data.ID == 6 && data.Status == 4 && data.Result == `{"error":"authentication required"}` && !data.EndTime.IsZero()
+		})).Return(&domain.ScanResult{ID: 6, Status: "Failure"}, nil).Once()
+		mockScanRepository.On("StoreAttempt", mock.MatchedBy(func(attempt *domain.AttemptData) bool {
+			return attempt.Error == "authentication required" && !attempt.Transient && attempt.RetryTime.IsZero()
+		})).Return(nil).Once()
+
+		runScanQueue(t, &domain.ScanJob{ID: 11, ResultID: 6, RepoID: 1, Kind: "scan", Attempts: 1}, mockScanRepository, mockRepoRepository, mockJobRepository)
+		mockScanRepository.AssertExpectations(t)
+	})
+
+	t.Run("abandoned", func(t *testing.T) {
+		mockScanRepository := new(mocks.ScanRepository)
+		mockRepoRepository := new(mocks.RepoRepository)
+		mockJobRepository := new(mocks.JobRepository)
+		mockScanRepository.On("FindByID", int64(7)).Return(&domain.ScanResult{ID: 7, Status: "In Progress"}, nil).Once()
+		mockScanRepository.On("Update", mock.MatchedBy(func(data *domain.ScanData) bool {
+			return data.ID == 7 && data.Status == 4 && data.Result == `{"error":"scan abandoned after 3 attempts"}`
+		})).Return(&domain.ScanResult{ID: 7, Status: "Failure"}, nil).Once()
+		mockScanRepository.On("StoreAttempt", mock.MatchedBy(func(attempt *domain.AttemptData) bool {
+			return attempt.ResultID == 7 && attempt.Error == "scan abandoned after 3 attempts"
+		})).Return(nil).Once()
+
+		runScanQueue(t, &domain.ScanJob{ID: 12, ResultID: 7, RepoID: 1, Kind: "scan", Attempts: 4}, mockScanRepository, mockRepoRepository, mockJobRepository)
+		mockScanRepository.AssertExpectations(t)
+		mockScanRepository.AssertNotCalled(t, "Start", mock.Anything)
+	})
+}
+
+// Test the retries of the transient failures
+func TestScanQueueRetry(t *testing.T) {
+	unreachable := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	t.Run("retried", func(t *testing.T) {
+		repo := &domain.Repo{ID: 1, Url: "www.test.com/repo"}
+		job := &domain.ScanJob{ID: 13, ResultID: 8, RepoID: 1, Kind: "scan", Attempts: 1, Retries: 1}
+		mockScanRepository := new(mocks.ScanRepository)
+		mockScanRepository.On("StoreProgress", job.ResultID, mock.AnythingOfType("*domain.ScanProgress")).Return(nil).Maybe()
+		mockRepoRepository := new(mocks.RepoRepository)
+		mockJobRepository := new(mocks.JobRepository)
+		retried := make(chan struct{})
+		mockJobRepository.On("Claim", mock.Anything, time.Minute).Return(job, nil).Once()
+		mockJobRepository.On("Claim", mock.Anything, time.Minute).Return(nil, nil).Maybe()
+		mockScanRepository.On("FindByID", int64(8)).Return(&domain.ScanResult{ID: 8}, nil).Once()
+		mockScanRepository.On("Start", mock.AnythingOfType("*domain.ScanData")).Return(&domain.ScanResult{ID: 8}, nil).Once()
+		mockRepoRepository.On("FindByID", int64(1)).Return(repo, nil).Twice()
+		mockScanRepository.On("Scan", mock.Anything, repo, (*domain.ScanOptions)(nil)).Return(nil, unreachable).Once()
+		mockScanRepository.On("StoreAttempt", mock.MatchedBy(func(attempt *domain.AttemptData) bool {
+			return attempt.Attempt == 2 && attempt.Transient && attempt.Error == unreachable.Error() && !attempt.RetryTime.IsZero()
+		})).Return(nil).Once()
+		// Second retry waits between the half and the whole of twice the base delay
+		mockJobRepository.On("Retry", job, mock.MatchedBy(func(delay time.Duration) bool {
+			return delay >= time.Second && delay <= 2*time.Second
+		})).Return(nil).Once()
+		// Result waits queued for the retry instead of running with the old owner
+		mockScanRepository.On("Requeue", int64(8)).Return(nil).Run(func(mock.Arguments) {
+			close(retried)
+		}).Once()
+
+		scanQueue := interfaces.NewScanQueue(
+			usecases.ScanInteractor{ScanRepository: mockScanRepository},
+			usecases.RepoInteractor{RepoRepository: mockRepoRepository},
+			usecases.JobInteractor{JobRepository: mockJobRepository},
+			zap.NewNop(),
+		)
+		scanQueue.PollInterval = 10 * time.Millisecond
+		scanQueue.RetryBaseDelay = time.Second
+		scanQueue.Start(1)
+		select {
+		case <-retried:
+		case <-time.After(5 * time.Second):
+			t.Error("job was not retried")
+		}
+		scanQueue.Close()
+		mockScanRepository.AssertExpectations(t)
+		mockScanRepository.AssertNotCalled(t, "Update", mock.Anything)
+		mockJobRepository.AssertNotCalled(t, "Complete", mock.Anything)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		maxRetries := 1
+		repo := &domain.Repo{ID: 1, Url: "www.test.com/repo", MaxRetries: &maxRetries}
+		mockScanRepository := new(mocks.ScanRepository)
+		mockRepoRepository := new(mocks.RepoRepository)
+		mockJobRepository := new(mocks.JobRepository)
+		mockScanRepository.On("FindByID", int64(9)).Return(&domain.ScanResult{ID: 9}, nil).Once()
+		mockScanRepository.On("Start", mock.AnythingOfType("*domain.ScanData")).Return(&domain.ScanResult{ID: 9}, nil).Once()
+		mockRepoRepository.On("FindByID", int64(1)).Return(repo, nil).Twice()
+		mockScanRepository.On("Scan", mock.Anything, repo, (*domain.ScanOptions)(nil)).Return(nil, unreachable).Once()
+		mockScanRepository.On("StoreAttempt", mock.MatchedBy(func(attempt *domain.AttemptData) bool {
+			return attempt.Attempt == 2 && attempt.Transient && attempt.RetryTime.IsZero()
+		})).Return(nil).Once()
+		mockScanRepository.On("Update", mock.MatchedBy(func(data *domain.ScanData) bool {
+			return data.ID == 9 && data.Status == 4
+		})).Return(&domain.ScanResult{ID: 9, Status: "Failure"}, nil).Once()
+
+		runScanQueue(t, &domain.ScanJob{ID: 14, ResultID: 9, RepoID: 1, Kind: "scan", Attempts: 1, Retries: 1}, mockScanRepository, mockRepoRepository, mockJobRepository)
+		mockScanRepository.AssertExpectations(t)
+		mockJobRepository.AssertNotCalled(t, "Retry", mock.Anything, mock.Anything)
+	})
+}
+
+// Test the renewal of the lease while the scan runs
+func TestScanQueueHeartbeat(t *testing.T) {
+	repo := &domain.Repo{ID: 1, Url: "www.test.com/repo"}
+	job := &domain.ScanJob{ID: 9, ResultID: 4, RepoID: 1, Kind: "scan", Attempts: 1}
+	mockScanRepository := new(mocks.ScanRepository)
+	mockScanRepository.On("StoreProgress", job.ResultID, mock.AnythingOfType("*domain.ScanProgress")).Return(nil).Maybe()
+	mockRepoRepository := new(mocks.RepoRepository)
+	mockJobRepository := new(mocks.JobRepository)
+	completed := make(chan struct{})
+	mockJobRepository.On("Claim", mock.Anything, 30*time.Millisecond).Return(job, nil).Once()
+	mockJobRepository.On("Claim", mock.Anything, 30*time.Millisecond).Return(nil, nil).Maybe()
+	mockJobRepository.On("Renew", job, 30*time.Millisecond).Return(nil)
+	mockJobRepository.On("Complete", job).Return(nil).Run(func(mock.Arguments) {
+		close(completed)
+	}).Once()
+	mockScanRepository.On("FindByID", int64(4)).Return(&domain.ScanResult{ID: 4}, nil).Once()
+	mockScanRepository.On("Start", mock.AnythingOfType("*domain.ScanData")).Return(&domain.ScanResult{ID: 4}, nil).Once()
+	mockRepoRepository.On("FindByID", int64(1)).Return(repo, nil).Once()
+	mockScanRepository.On("Scan", mock.Anything, repo, (*domain.ScanOptions)(nil)).Return(&domain.ScanData{Status: 3}, nil).Run(func(mock.Arguments) {
+		time.Sleep(100 * time.Millisecond)
+	}).Once()
+	mockScanRepository.On("Update", mock.AnythingOfType("*domain.ScanData")).Return(&domain.ScanResult{ID: 4}, nil).Once()
+	mockScanRepository.On("StoreAttempt", mock.AnythingOfType("*domain.AttemptData")).Return(nil).Once()
+
+	scanQueue := interfaces.NewScanQueue(
+		usecases.ScanInteractor{ScanRepository: mockScanRepository},
+		usecases.RepoInteractor{RepoRepository: mockRepoRepository},
+		usecases.JobInteractor{JobRepository: mockJobRepository},
+		zap.NewNop(),
+	)
+	scanQueue.Lease = 30 * time.Millisecond
+	scanQueue.PollInterval = 10 * time.Millisecond
+	scanQueue.Start(1)
+	select {
+	case <-completed:
+	case <-time.After(5 * time.Second):
+		t.Error("job was not completed")
+	}
+	scanQueue.Close()
+	mockJobRepository.AssertCalled(t, "Renew", job, 30*time.Millisecond)
+}
+
+// Test the cancellation of the running scan
+func TestScanQueueCancel(t *testing.T) {
+	repo := &domain.Repo{ID: 1, Url: "www.test.com/repo"}
+	job := &domain.ScanJob{ID: 10, ResultID: 4, RepoID: 1, Kind: "scan", Attempts: 1}
+	mockScanRepository := new(mocks.ScanRepository)
+	mockScanRepository.On("StoreProgress", job.ResultID, mock.AnythingOfType("*domain.ScanProgress")).Return(nil).Maybe()
+	mockRepoRepository := new(mocks.RepoRepository)
+	mockJobRepository := new(mocks.JobRepository)
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	mockJobRepository.On("Claim", mock.Anything, time.Minute).Return(job, nil).Once()
+	mockJobRepository.On("Claim", mock.Anything, time.Minute).Return(nil, nil).Maybe()
+	mockJobRepository.On("Remove", int64(4)).Return(nil).Once()
+	mockScanRepository.On("FindByID", int64(4)).Return(&domain.ScanResult{ID: 4}, nil).Once()
+	mockScanRepository.On("Start", mock.AnythingOfType("*domain.ScanData")).Return(&domain.ScanResult{ID: 4}, nil).Once()
+	mockRepoRepository.On("FindByID", int64(1)).Return(repo, nil).Once()
+	mockScanRepository.On("Scan", mock.Anything, repo, (*domain.ScanOptions)(nil)).Return(nil, context.Canceled).Run(func(args mock.Arguments) {
+		close(started)
+		<-args.Get(0).(context.Context).Done()
+		close(stopped)
+	}).Once()
+
+	scanQueue := interfaces.NewScanQueue(
+		usecases.ScanInteractor{ScanRepository: mockScanRepository},
+		usecases.RepoInteractor{RepoRepository: mockRepoRepository},
+		usecases.JobInteractor{JobRepository: mockJobRepository},
+		zap.NewNop(),
+	)
+	scanQueue.PollInterval = 10 * time.Millisecond
+	scanQueue.Start(1)
+	<-started
+	assert.NoError(t, scanQueue.Cancel(4))
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("scan was not stopped")
+	}
+	scanQueue.Close()
+	mockJobRepository.AssertExpectations(t)
+	mockScanRepository.AssertNotCalled(t, "Update", mock.Anything)
+	mockJobRepository.AssertNotCalled(t, "Complete", mock.Anything)
+}
+
+// Test the drain of the queue which outlasts the running scan
+func TestScanQueueDrain(t *testing.T) {
+	repo := &domain.Repo{ID: 1, Url: "www.test.com/repo"}
+	job := &domain.ScanJob{ID: 10, ResultID: 4, RepoID: 1, Kind: "scan", Attempts: 1}
+	mockScanRepository := new(mocks.ScanRepository)
+	mockScanRepository.On("StoreProgress", job.ResultID, mock.AnythingOfType("*domain.ScanProgress")).Return(nil).Maybe()
+	mockRepoRepository := new(mocks.RepoRepository)
+	mockJobRepository := new(mocks.JobRepository)
+	started := make(chan struct{})
+	mockJobRepository.On("Claim", mock.Anything, time.Minute).Return(job, nil).Once()
+	mockJobRepository.On("Claim", mock.Anything, time.Minute).Return(nil, nil).Maybe()
+	mockJobRepository.On("Release", job).Return(nil).Once()
+	mockScanRepository.On("FindByID", int64(4)).Return(&domain.ScanResult{ID: 4}, nil).Once()
+	mockScanRepository.On("Start", mock.AnythingOfType("*domain.ScanData")).Return(&domain.ScanResult{ID: 4}, nil).Once()
+	mockScanRepository.On("Requeue", int64(4)).Return(nil).Once()
+	mockRepoRepository.On("FindByID", int64(1)).Return(repo, nil).Once()
+	mockScanRepository.On("Scan", mock.Anything, repo, (*domain.ScanOptions)(nil)).Return(nil, context.Canceled).Run(func(args mock.Arguments) {
+		close(started)
+		<-args.Get(0).(context.Context).Done()
+	}).Once()
+
+	scanQueue := interfaces.NewScanQueue(
+		usecases.ScanInteractor{ScanRepository: mockScanRepository},
+		usecases.RepoInteractor{RepoRepository: mockRepoRepository},
+		usecases.JobInteractor{JobRepository: mockJobRepository},
+		zap.NewNop(),
+	)
+	scanQueue.PollInterval = 10 * time.Millisecond
+	scanQueue.Start(1)
+	<-started
+	// Scan still running at the timeout is stopped and queued again
+	scanQueue.Drain(10 * time.Millisecond)
+	mockJobRepository.AssertExpectations(t)
+	mockScanRepository.AssertExpectations(t)
+	mockScanRepository.AssertNotCalled(t, "Update", mock.Anything)
+	mockJobRepository.AssertNotCalled(t, "Complete", mock.Anything)
+}
+
+// Test the drain which gives up on a scan that does not stop
+func TestScanQueueDrainGrace(t *testing.T) {
+	repo := &domain.Repo{ID: 1, Url: "www.test.com/repo"}
+	job := &domain.ScanJob{ID: 11, ResultID: 5, RepoID: 1, Kind: "scan", Attempts: 1}
+	mockScanRepository := new(mocks.ScanRepository)
+	mockScanRepository.On("StoreProgress", job.ResultID, mock.AnythingOfType("*domain.ScanProgress")).Return(nil).Maybe()
+	mockRepoRepository := new(mocks.RepoRepository)
+	mockJobRepository := new(mocks.JobRepository)
+	started := make(chan struct{})
+	stuck := make(chan struct{})
+	mockJobRepository.On("Claim", mock.Anything, time.Minute).Return(job, nil).Once()
+	mockJobRepository.On("Claim", mock.Anything, time.Minute).Return(nil, nil).Maybe()
+	mockJobRepository.On("Release", job).Return(nil).Maybe()
+	mockScanRepository.On("FindByID", int64(5)).Return(&domain.ScanResult{ID: 5}, nil).Once()
+	mockScanRepository.On("Start", mock.AnythingOfType("*domain.ScanData")).Return(&domain.ScanResult{ID: 5}, nil).Once()
+	mockScanRepository.On("Requeue", int64(5)).Return(nil).Maybe()
+	mockRepoRepository.On("FindByID", int64(1)).Return(repo, nil).Once()
+	// Scan ignores the cancellation of its context
+	mockScanRepository.On("Scan", mock.Anything, repo, (*domain.ScanOptions)(nil)).Return(nil, context.Canceled).Run(func(mock.Arguments) {
+		close(started)
+		<-stuck
+	}).Once()
+
+	scanQueue := interfaces.NewScanQueue(
+		usecases.ScanInteractor{ScanRepository: mockScanRepository},
+		usecases.RepoInteractor{RepoRepository: mockRepoRepository},
+		usecases.JobInteractor{JobRepository: mockJobRepository},
+		zap.NewNop(),
+	)
+	scanQueue.PollInterval = 10 * time.Millisecond
+	scanQueue.StopGrace = 20 * time.Millisecond
+	scanQueue.Start(1)
+	<-started
+	drained := make(chan struct{})
+	go func() {
+		scanQueue.Drain(50 * time.Millisecond)
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Error("drain waited for the scan which did not stop")
+	}
+	close(stuck)
+	scanQueue.Close()
+}
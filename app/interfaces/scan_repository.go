@@ -2,12 +2,15 @@ package interfaces
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +26,39 @@ type ScanRepository struct {
 	ScanCloneFolder       string
 	ScanCloneFolderPrefix string
 	NoOfWorkers           int
+	// Slots of the file workers of all the scans, nil is unlimited
+	FileWorkers *WorkerPool
+	// Mirrors of the repos, nil clones every scan from the remote
+	MirrorCache *MirrorCache
+	// Blame of the recently scanned files
+	BlameCache *BlameCache
+	// Maximum total size in bytes and number of the files extracted from an uploaded archive
+	ExtractMaxSize  int64
+	ExtractMaxFiles int
+	// Allowed root directories of the local sources
+	LocalRoots []string
+	// "objects" scans the blobs of the git object storage, otherwise the checked out worktree
+	ScanMode string
+	// Maximum size in bytes of the objects of a repo cloned into the memory, the larger repos
+	// and all the repos when it is 0 are cloned into the clone folder
+	MemoryMaxSize int64
+	// Maximum nesting of encoded payloads to decode, 0 disables the decoding
+	DecodeMaxDepth int
+	// Maximum size in bytes of a decoded payload
+	DecodeMaxSize int
+	// Keywords of the keyword proximity rule, empty disables the rule
+	ProximityKeywords []string
+	// Values matching the pattern are reported regardless of their entropy
+	ProximityValuePattern *regexp.Regexp
+	ProximityMinEntropy   float64
+	// Maximum number of characters between the keyword and the value
+	ProximityDistance int
+	// Instance which runs the started scans, the scans of a dead instance are recovered
+	Owner string
+
+	// Clone directories of the running scans, the other ones are swept
+	clonesMu sync.Mutex
+	clones   map[string]struct{}
 }
 
 // Struct for scan result
@@ -40,17 +76,31 @@ type finding struct {
 }
 
 type metadata struct {
-	Description string `json:"description"`
-	Severity    string `json:"severity"`
+	Description string       `json:"description"`
+	Severity    string       `json:"severity"`
+	Certificate *certificate `json:"certificate,omitempty"`
+	Token       *token       `json:"token,omitempty"`
+	Connection  *connection  `json:"connection,omitempty"`
+	// Encodings which were decoded to find the violation, e.g. base64 -> X.509 certificate
+	DecodingChain string     `json:"decoding_chain,omitempty"`
+	Proximity     *proximity `json:"proximity,omitempty"`
+	// Finding is on a line which the scanned diff adds
+	IntroducedInDiff bool `json:"introduced_in_diff,omitempty"`
 }
 
 type location struct {
-	Path      string     `json:"path"`
+	Path string `json:"path"`
+	// Hash of the git blob of the file, only in the objects scan mode
+	Blob      string     `json:"blob,omitempty"`
 	Positions []position `json:"positions"`
 }
 
 type position struct {
 	Begin begin `json:"begin"`
+	// Commit which last changed the line, only when the scan blames the findings
+	Blame *attribution `json:"blame,omitempty"`
+	// Exposure of the matched value in the history, only when the scan follows the timeline
+	Exposure *exposure `json:"exposure,omitempty"`
 }
 
 type begin struct {
@@ -58,10 +108,21 @@ type begin struct {
 	Cols []string `json:"cols"`
 }
 
+// Struct for a file to scan, the content is read by the worker
+type scanJob struct {
+	path string
+	blob string
+	read func() ([]byte, error)
+	// Lines added by the diff, nil reports the findings of the whole file
+	lines map[int]bool
+	// Findings are introduced by the diff
+	introduced bool
+}
+
 type resultWrapper struct {
-	path      string
-	positions []position
-	err       error
+	path     string
+	findings findings
+	err      error
 }
 
 type jsonResultWrapper struct {
@@ -69,60 +130,207 @@ type jsonResultWrapper struct {
 	err    error
 }
 
-// Scan the repository.
-func (sr *ScanRepository) Scan(repoUrl string) (scanData *domain.ScanData, err error) {
-	// Create temporary directory to clone the repo
-	directory, err := ioutil.TempDir(sr.ScanCloneFolder, sr.ScanCloneFolderPrefix)
+// Scan the repository. The findings of a cloned worktree are blamed and followed through the
+// history when the options enable it. Cancelling the context stops the clone and the workers,
+// and the scan returns the error of the context.
+func (sr *ScanRepository) Scan(ctx context.Context, repo *domain.Repo, options *domain.ScanOptions) (scanData *domain.ScanData, err error) {
+	auth, err := cloneAuth(repo.Credential)
+	if err != nil {
+		return
+	}
+	strategy := effectiveStrategy(repo.CloneStrategy)
+
+	var (
+		walk func(jobs chan<- scanJob) error
+		// Commit which is scanned, empty for the worktrees of the local sources
+		commit string
+		// Annotations of the scan output which read the history of the repo
+		annotations []func(output string) (string, error)
+	)
+	if repo.SourceType == "local" {
+		if walk, commit, err = sr.localWalk(repo, strategy); err != nil {
+			return
+		}
+	} else if sr.ScanMode == "objects" {
+		// The blobs are read from the object storage without a worktree. The storage is in the
+		// memory unless the objects exceed the memory limit, then it is a bare clone on the disk
+		progressFrom(ctx).setPhase(phaseCloning)
+		tree, hash, release, err := sr.objectTree(ctx, repo, strategy, auth)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		commit = hash.String()
+		walk = func(jobs chan<- scanJob) error {
+			return walkObjects(tree, strategy.SparsePaths, jobs)
+		}
+	} else {
+		// Create temporary directory to clone the repo
+		directory, remove, err := sr.cloneDir()
+		if err != nil {
+			return nil, err
+		}
+		// Delete the directory after scanning
+		defer remove()
+
+		// Cloning in configured folder
+		progressFrom(ctx).setPhase(phaseCloning)
+		var gitRepo *git.Repository
+		if sr.MirrorCache != nil {
+			var release func()
+			gitRepo, release, err = sr.MirrorCache.checkout(ctx, directory, repo, strategy, auth)
+			if err == nil {
+				// History is read from the mirror, which is locked until the scan ends
+				if options != nil && (options.Blame || options.Timeline) {
+					defer release()
+				} else {
+					release()
+				}
+			}
+		} else {
+			gitRepo, err = sr.clone(ctx, directory, repo, strategy, auth)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hash, headErr := headCommit(gitRepo); headErr == nil {
+			commit = hash.String()
+		}
+		walk = func(jobs chan<- scanJob) error {
+			return walkWorktree(directory, nil, jobs)
+		}
+		if options != nil && options.Timeline {
+			annotations = append(annotations, func(output string) (string, error) {
+				return sr.timelineFindings(ctx, gitRepo, directory, output)
+			})
+		}
+		if options != nil && options.Blame {
+			annotations = append(annotations, func(output string) (string, error) {
+				return sr.blameFindings(ctx, gitRepo, directory, output)
+			})
+		}
+	}
+
+	scanData = sr.scanFiles(ctx, walk)
+	scanData.CloneStrategy = strategy
+	scanData.Options = options
+	scanData.Commit = commit
+	for _, annotate := range annotations {
+		if scanData.Status != 3 || ctx.Err() != nil {
+			break
+		}
+		if scanData.Result, err = annotate(scanData.Result); err != nil {
+			return nil, err
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// ScanArchive scans the files of the uploaded tar.gz or zip archive until the context is
+// cancelled.
+func (sr *ScanRepository) ScanArchive(ctx context.Context, upload *domain.Upload, archive io.ReaderAt) (scanData *domain.ScanData, err error) {
+	// Create temporary directory to extract the archive
+	directory, remove, err := sr.cloneDir()
 	if err != nil {
 		return
 	}
 	// Delete the directory after scanning
-	defer os.RemoveAll(directory)
+	defer remove()
 
-	// Cloning in configured folder
-	_, err = git.PlainClone(directory, false, &git.CloneOptions{
-		URL:               repoUrl,
-		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+	archiveExtractor := &extractor{
+		directory: directory,
+		maxSize:   sr.ExtractMaxSize,
+		maxFiles:  sr.ExtractMaxFiles,
+	}
+	switch upload.Format {
+	case "tar.gz":
+		err = archiveExtractor.extractTarGz(io.NewSectionReader(archive, 0, upload.Size))
+	case "zip":
+		err = archiveExtractor.extractZip(archive, upload.Size)
+	default:
+		err = fmt.Errorf("%w: unsupported format %s", errInvalidArchive, upload.Format)
+	}
+	if err != nil {
+		return
+	}
+
+	scanData = sr.scanFiles(ctx, func(jobs chan<- scanJob) error {
+		return walkWorktree(directory, nil, jobs)
 	})
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// ScanContent scans the content in memory, the filename is the path of the findings.
+func (sr *ScanRepository) ScanContent(filename string, content []byte) (scanData *domain.ScanData, err error) {
+	fileFindings, err := sr.checkViolation(scanJob{path: filename, read: func() ([]byte, error) {
+		return content, nil
+	}})
 	if err != nil {
 		return
 	}
+	output, err := json.MarshalIndent(result{Findings: fileFindings}, "", "  ")
+	if err != nil {
+		return
+	}
+	scanData = &domain.ScanData{
+		Status:  3,
+		Result:  string(output),
+		EndTime: time.Now().UTC(),
+	}
+	return
+}
 
-	jobs := make(chan string, sr.NoOfWorkers)
+// Pass the files of the walk to the workers and merge their findings into the scan data. The
+// workers skip the remaining files once the context is cancelled.
+func (sr *ScanRepository) scanFiles(ctx context.Context, walk func(jobs chan<- scanJob) error) (scanData *domain.ScanData) {
+	jobs := make(chan scanJob, sr.NoOfWorkers)
 	results := make(chan resultWrapper, sr.NoOfWorkers)
 	var wg sync.WaitGroup
 	// Spawn go routines to scan the security violation
 	// each file will be scanned by each worker
 	for w := 1; w <= sr.NoOfWorkers; w++ {
+		// First worker waits for a slot of the pool, the others only start when one is free
+		if w > 1 && !sr.FileWorkers.tryAcquire() {
+			break
+		}
 		wg.Add(1)
-		go sr.worker(w, &wg, jobs, results)
+		go func(w int) {
+			defer wg.Done()
+			// Jobs of the cancelled scan are drained without a slot
+			if w > 1 || sr.FileWorkers.acquire(ctx) {
+				defer sr.FileWorkers.release()
+			}
+			sr.worker(ctx, w, jobs, results)
+		}(w)
 	}
 	jsonResult := make(chan jsonResultWrapper)
 	go sr.processResults(results, jsonResult)
 
-	// Traverse each file recursively and pass the file to the worker to check violation
-	err = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if info.IsDir() && info.Name() == ".git" {
-			return filepath.SkipDir
-		}
-
-		if !info.IsDir() {
-			jobs <- path
-		}
-		return nil
-	})
+	progress := progressFrom(ctx)
+	progress.setPhase(phaseWalking)
+	walkErr := walk(jobs)
 	close(jobs)
+	progress.setPhase(phaseScanning)
 
 	wg.Wait()
 	close(results)
 	// Final json output
 	jsonResultWrap := <-jsonResult
 	output := jsonResultWrap.output
-	err = jsonResultWrap.err
+	err := jsonResultWrap.err
+	// Files which could not be walked fail the scan as well
+	if err == nil {
+		err = walkErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
 	scanData = &domain.ScanData{
 		EndTime: time.Now().UTC(),
 	}
@@ -136,6 +344,39 @@ func (sr *ScanRepository) Scan(repoUrl string) (scanData *domain.ScanData, err e
 	return
 }
 
+// Traverse each file recursively and pass the file to the worker to check violation.
+// Only the files of the sparse paths are passed when they are given.
+func walkWorktree(directory string, sparsePaths []string, jobs chan<- scanJob) error {
+	return filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		// Symlinks are not followed, they can point outside of the directory
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		if len(sparsePaths) > 0 && !info.IsDir() {
+			rel, _ := filepath.Rel(directory, path)
+			if !inSparsePaths(filepath.ToSlash(rel), sparsePaths) {
+				return nil
+			}
+		}
+
+		if !info.IsDir() {
+			jobs <- scanJob{path: path, read: func() ([]byte, error) {
+				return os.ReadFile(path)
+			}}
+		}
+		return nil
+	})
+}
+
 // Merge all the results and send it to the caller
 func (sr *ScanRepository) processResults(results chan resultWrapper, jsonResult chan jsonResultWrapper) {
 	var (
@@ -147,44 +388,82 @@ func (sr *ScanRepository) processResults(results chan resultWrapper, jsonResult
 	// Receiving results from all the channels
 	// and merge all of them
 	for resultWrapper := range results {
-		err := resultWrapper.err
+		// Results after the first error are drained, so the workers never block on them
 		if err != nil {
-			jsonResult <- jsonResultWrapper{err: err}
-			return
+			continue
+		}
+		if err = resultWrapper.err; err != nil {
+			continue
 		}
-		positions := resultWrapper.positions
 		// If violation is found, add it in the result
-		if len(positions) > 0 {
-			findingsOutput = append(findingsOutput, finding{
-				ErrorType: "sast",
-				RuleID:    "G402",
-				Location: location{
-					Path:      resultWrapper.path,
-					Positions: positions,
-				},
-				Metadata: metadata{
-					Description: "Private/Public key is present",
-					Severity:    "HIGH",
-				},
-			})
+		if len(resultWrapper.findings) > 0 {
+			findingsOutput = append(findingsOutput, resultWrapper.findings...)
 			resultOutput = result{Findings: findingsOutput}
-
 		}
 	}
+	if err != nil {
+		jsonResult <- jsonResultWrapper{err: err}
+		return
+	}
 	output, err = json.MarshalIndent(resultOutput, "", "  ")
 	jsonResult <- jsonResultWrapper{output: output, err: err}
 	return
 }
 
-// Check security violation in the given path
-func (sr *ScanRepository) checkViolation(path string) (positions []position, err error) {
-	var file *os.File
-	file, err = os.Open(path)
-	// If any error while opening the file, we need to report
+// Check security violation in the given file
+func (sr *ScanRepository) checkViolation(job scanJob) (fileFindings findings, err error) {
+	var content []byte
+	content, err = job.read()
+	// If any error while reading the file, we need to report
+	if err != nil {
+		return
+	}
+	fileFindings, err = sr.checkContent(job.path, content)
 	if err != nil {
 		return
 	}
-	Scanner := bufio.NewScanner(file)
+	// Run the checks on the encoded payloads of the file
+	fileFindings = append(fileFindings, sr.checkDecoded(job.path, content, 1)...)
+	if job.lines != nil {
+		fileFindings = addedFindings(fileFindings, job.lines)
+	}
+	for i := range fileFindings {
+		fileFindings[i].Location.Blob = job.blob
+		fileFindings[i].Metadata.IntroducedInDiff = job.introduced
+	}
+	return
+}
+
+// Check the content with the search patterns and the detectors
+func (sr *ScanRepository) checkContent(path string, content []byte) (fileFindings findings, err error) {
+	positions, err := sr.checkPatterns(content)
+	if err != nil {
+		return
+	}
+	if len(positions) > 0 {
+		fileFindings = append(fileFindings, finding{
+			ErrorType: "sast",
+			RuleID:    "G402",
+			Location: location{
+				Path:      path,
+				Positions: positions,
+			},
+			Metadata: metadata{
+				Description: "Private/Public key is present",
+				Severity:    "HIGH",
+			},
+		})
+	}
+	// Run the content detectors on the whole file
+	for _, d := range sr.detectors() {
+		fileFindings = append(fileFindings, d.detect(path, content)...)
+	}
+	return
+}
+
+// Check the configured search patterns line by line
+func (sr *ScanRepository) checkPatterns(content []byte) (positions []position, err error) {
+	Scanner := bufio.NewScanner(bytes.NewReader(content))
 	lineCount := 1
 	for Scanner.Scan() {
 		// Read line by line
@@ -222,11 +501,17 @@ func (sr *ScanRepository) CheckViolationInWord(words []string) (errCols []string
 }
 
 // Each worker will process each file
-func (sr *ScanRepository) worker(id int, wg *sync.WaitGroup, jobs <-chan string, results chan<- resultWrapper) {
-	defer wg.Done()
-	for path := range jobs {
-		positions, err := sr.checkViolation(path)
-		results <- resultWrapper{path: path, positions: positions, err: err}
+func (sr *ScanRepository) worker(ctx context.Context, id int, jobs <-chan scanJob, results chan<- resultWrapper) {
+	progress := progressFrom(ctx)
+	for job := range jobs {
+		// Jobs of a cancelled scan are drained without reading the files
+		if ctx.Err() != nil {
+			continue
+		}
+		progress.discover()
+		fileFindings, err := sr.checkViolation(job)
+		progress.scan(len(fileFindings))
+		results <- resultWrapper{path: job.path, findings: fileFindings, err: err}
 	}
 }
 
@@ -235,51 +520,82 @@ func (sr *ScanRepository) FindAll() (scanResults *domain.ScanResults, err error)
 	const query = `
 		SELECT
 			sr.id,
-			r.name,
-			r.url,
+			COALESCE(r.name, u.name),
+			COALESCE(r.url, ''),
 			sr.status,
 			sr.result,
 			sr.queue_time,
 			sr.start_time,
-			sr.end_time
+			sr.end_time,
+			sr.clone_strategy,
+			sr.upload_id,
+			sr.base_ref,
+			sr.head_ref,
+			sr.options,
+			sr.cancelled_time,
+			sr.cancelled_by
 		FROM
 			scan_results sr 
-		JOIN 
+		LEFT JOIN 
 			repositories r 
 		ON sr.repo_id = r.id
+		LEFT JOIN
+			uploads u
+		ON sr.upload_id = u.id
 	`
 	rows, err := sr.SQLHandler.Query(query)
-
-	defer rows.Close()
-
 	if err != nil {
 		return
 	}
+	defer rows.Close()
+
 	scanResults = &domain.ScanResults{}
 	for rows.Next() {
 		var (
-			id        int64
-			name      string
-			url       string
-			status    string
-			result    string
-			queueTime sql.NullString
-			startTime sql.NullString
-			endTime   sql.NullString
+			id            int64
+			name          string
+			url           string
+			status        string
+			result        string
+			queueTime     sql.NullString
+			startTime     sql.NullString
+			endTime       sql.NullString
+			strategy      sql.NullString
+			uploadID      sql.NullInt64
+			baseRef       sql.NullString
+			headRef       sql.NullString
+			options       sql.NullString
+			cancelledTime sql.NullString
+			cancelledBy   sql.NullString
 		)
-		if err = rows.Scan(&id, &name, &url, &status, &result, &queueTime, &startTime, &endTime); err != nil {
+		if err = rows.Scan(&id, &name, &url, &status, &result, &queueTime, &startTime, &endTime, &strategy, &uploadID, &baseRef, &headRef, &options, &cancelledTime, &cancelledBy); err != nil {
+			return
+		}
+		var cloneStrategy *domain.CloneStrategy
+		if cloneStrategy, err = decodeStrategy(strategy); err != nil {
+			return
+		}
+		var scanOptions *domain.ScanOptions
+		if scanOptions, err = decodeOptions(options); err != nil {
 			return
 		}
 
 		scanResult := domain.ScanResult{
-			ID:        id,
-			Name:      name,
-			Url:       url,
-			Status:    status,
-			Result:    result,
-			QueueTime: queueTime.String,
-			StartTime: startTime.String,
-			EndTime:   endTime.String,
+			ID:            id,
+			Name:          name,
+			Url:           url,
+			Status:        status,
+			Result:        result,
+			QueueTime:     queueTime.String,
+			StartTime:     startTime.String,
+			EndTime:       endTime.String,
+			CloneStrategy: cloneStrategy,
+			UploadID:      uploadID.Int64,
+			BaseRef:       baseRef.String,
+			HeadRef:       headRef.String,
+			Options:       scanOptions,
+			CancelledTime: cancelledTime.String,
+			CancelledBy:   cancelledBy.String,
 		}
 		*scanResults = append(*scanResults, scanResult)
 	}
@@ -296,56 +612,100 @@ func (sr *ScanRepository) FindByID(resultID int64) (scanResult *domain.ScanResul
 	const query = `
 		SELECT
 			sr.id,
-			r.name,
-			r.url,
+			COALESCE(r.name, u.name),
+			COALESCE(r.url, ''),
 			sr.status,
 			sr.result,
 			sr.queue_time,
 			sr.start_time,
-			sr.end_time
+			sr.end_time,
+			sr.clone_strategy,
+			sr.upload_id,
+			sr.base_ref,
+			sr.head_ref,
+			sr.options,
+			sr.cancelled_time,
+			sr.cancelled_by,
+			sr.progress,
+			sr.commit_hash,
+			sr.ruleset
 		FROM
 			scan_results sr 
-		JOIN 
+		LEFT JOIN 
 			repositories r 
 		ON sr.repo_id = r.id
+		LEFT JOIN
+			uploads u
+		ON sr.upload_id = u.id
 		WHERE
 			sr.id = ?
 	`
 	row, err := sr.SQLHandler.Query(query, resultID)
-
-	defer row.Close()
-
 	if err != nil {
 		return
 	}
+	defer row.Close()
 
 	var (
-		id        int64
-		name      string
-		url       string
-		status    string
-		result    string
-		queueTime sql.NullString
-		startTime sql.NullString
-		endTime   sql.NullString
+		id            int64
+		name          string
+		url           string
+		status        string
+		result        string
+		queueTime     sql.NullString
+		startTime     sql.NullString
+		endTime       sql.NullString
+		strategy      sql.NullString
+		uploadID      sql.NullInt64
+		baseRef       sql.NullString
+		headRef       sql.NullString
+		options       sql.NullString
+		cancelledTime sql.NullString
+		cancelledBy   sql.NullString
+		progress      sql.NullString
+		commit        sql.NullString
+		ruleset       sql.NullString
 	)
 	if !row.Next() {
 		return
 	}
-	if err = row.Scan(&id, &name, &url, &status, &result, &queueTime, &startTime, &endTime); err != nil {
+	if err = row.Scan(&id, &name, &url, &status, &result, &queueTime, &startTime, &endTime, &strategy, &uploadID, &baseRef, &headRef, &options, &cancelledTime, &cancelledBy, &progress, &commit, &ruleset); err != nil {
+		return
+	}
+	cloneStrategy, err := decodeStrategy(strategy)
+	if err != nil {
+		return
+	}
+	scanOptions, err := decodeOptions(options)
+	if err != nil {
+		return
+	}
+	scanProgress, err := decodeProgress(progress)
+	if err != nil {
 		return
 	}
 
 	scanResult = &domain.ScanResult{
-		ID:        id,
-		Name:      name,
-		Url:       url,
-		Status:    status,
-		Result:    result,
-		QueueTime: queueTime.String,
-		StartTime: startTime.String,
-		EndTime:   endTime.String,
+		ID:            id,
+		Name:          name,
+		Url:           url,
+		Status:        status,
+		Result:        result,
+		QueueTime:     queueTime.String,
+		StartTime:     startTime.String,
+		EndTime:       endTime.String,
+		CloneStrategy: cloneStrategy,
+		UploadID:      uploadID.Int64,
+		BaseRef:       baseRef.String,
+		HeadRef:       headRef.String,
+		Options:       scanOptions,
+		CancelledTime: cancelledTime.String,
+		CancelledBy:   cancelledBy.String,
+		Progress:      scanProgress,
+		Commit:        commit.String,
+		Ruleset:       ruleset.String,
 	}
+	scanResult.Attempts, err = sr.findAttempts(resultID)
 
 	return
 }
@@ -355,12 +715,22 @@ func (sr *ScanRepository) Store(scanData *domain.ScanData) (newScanResult *domai
 	query := `
 		INSERT INTO scan_results (
 			repo_id,
+			upload_id,
+			base_ref,
+			head_ref,
+			options,
 			status,
 			result,
 			queue_time,
-			start_time
+			start_time,
+			owner
 		)
 		VALUES (
+			?,
+			?,
+			?,
+			?,
+			?,
 			?,
 			?,
 			?,
@@ -368,8 +738,20 @@ func (sr *ScanRepository) Store(scanData *domain.ScanData) (newScanResult *domai
 			?
 		)
 	`
+	// Scans of an uploaded archive are not linked to a repo
+	repoID := sql.NullInt64{Int64: scanData.RepoID, Valid: scanData.RepoID != 0}
+	uploadID := sql.NullInt64{Int64: scanData.UploadID, Valid: scanData.UploadID != 0}
+	baseRef := sql.NullString{String: scanData.BaseRef, Valid: scanData.BaseRef != ""}
+	headRef := sql.NullString{String: scanData.HeadRef, Valid: scanData.HeadRef != ""}
+	// Queued scans are not started yet, the started ones run on this instance
+	startTime := sql.NullTime{Time: scanData.StartTime, Valid: !scanData.StartTime.IsZero()}
+	owner := sql.NullString{String: sr.Owner, Valid: startTime.Valid && sr.Owner != ""}
+	options, err := encodeOptions(scanData.Options)
+	if err != nil {
+		return
+	}
 	var row Result
-	row, err = sr.SQLHandler.Exec(query, scanData.RepoID, scanData.Status, scanData.Result, scanData.QueueTime, scanData.StartTime)
+	row, err = sr.SQLHandler.Exec(query, repoID, uploadID, baseRef, headRef, options, scanData.Status, scanData.Result, scanData.QueueTime, startTime, owner)
 	if err != nil {
 		return
 	}
@@ -387,26 +769,352 @@ func (sr *ScanRepository) Store(scanData *domain.ScanData) (newScanResult *domai
 	return
 }
 
-// Update is to update the existing entity.
+// Start is to mark the queued entity as started, a cancelled entity is kept as it is.
+func (sr *ScanRepository) Start(scanData *domain.ScanData) (updScanResult *domain.ScanResult, err error) {
+	query := `
+		UPDATE scan_results
+		SET
+			status = ?,
+			start_time = ?,
+			owner = ?
+		WHERE
+			id = ?
+			AND status <> 5
+	`
+	owner := sql.NullString{String: sr.Owner, Valid: sr.Owner != ""}
+	_, err = sr.SQLHandler.Exec(query, scanData.Status, scanData.StartTime, owner, scanData.ID)
+	if err != nil {
+		return
+	}
+	updScanResult = &domain.ScanResult{
+		ID:     scanData.ID,
+		Status: sr.getStatus(scanData.Status),
+	}
+
+	return
+}
+
+// Requeue is to mark the running entity as queued again.
+func (sr *ScanRepository) Requeue(resultID int64) (err error) {
+	query := `
+		UPDATE scan_results
+		SET
+			status = 1,
+			owner = NULL
+		WHERE
+			id = ?
+			AND status = 2
+	`
+	_, err = sr.SQLHandler.Exec(query, resultID)
+
+	return
+}
+
+// Update is to update the existing entity, the result of a cancelled entity is discarded. The
+// commit, ruleset and scan key are replaced when the scan key is given.
 func (sr *ScanRepository) Update(scanData *domain.ScanData) (updScanResult *domain.ScanResult, err error) {
 	query := `
 		UPDATE scan_results 
 		SET
 			result = ?,
 			status = ?,
-			end_time = ?
+			end_time = ?,
+			clone_strategy = ?,
+			commit_hash = COALESCE(?, commit_hash),
+			ruleset = COALESCE(?, ruleset),
+			scan_key = COALESCE(?, scan_key)
 		WHERE
 			id = ?
+			AND status <> 5
 	`
 
-	_, err = sr.SQLHandler.Exec(query, scanData.Result, scanData.Status, scanData.EndTime, scanData.ID)
+	strategy, err := encodeStrategy(scanData.CloneStrategy)
+	if err != nil {
+		return
+	}
+	keyed := scanData.ScanKey != ""
+	commit := sql.NullString{String: scanData.Commit, Valid: keyed}
+	ruleset := sql.NullString{String: scanData.Ruleset, Valid: keyed}
+	scanKey := sql.NullString{String: scanData.ScanKey, Valid: keyed}
+	_, err = sr.SQLHandler.Exec(query, scanData.Result, scanData.Status, scanData.EndTime, strategy, commit, ruleset, scanKey, scanData.ID)
 	if err != nil {
 		return
 	}
 	updScanResult = &domain.ScanResult{
-		ID:     scanData.ID,
-		Status: sr.getStatus(scanData.Status),
-		Result: scanData.Result,
+		ID:            scanData.ID,
+		Status:        sr.getStatus(scanData.Status),
+		Result:        scanData.Result,
+		CloneStrategy: scanData.CloneStrategy,
+		BaseRef:       scanData.BaseRef,
+		HeadRef:       scanData.HeadRef,
+		Options:       scanData.Options,
+		Commit:        scanData.Commit,
+	}
+
+	return
+}
+
+// Cancel is to mark the queued or running entity as cancelled. It returns nil when the entity
+// is not queued or running.
+func (sr *ScanRepository) Cancel(scanData *domain.ScanData) (updScanResult *domain.ScanResult, err error) {
+	query := `
+		UPDATE scan_results
+		SET
+			status = 5,
+			end_time = ?,
+			cancelled_time = ?,
+			cancelled_by = ?
+		WHERE
+			id = ?
+			AND status IN (1, 2)
+	`
+	row, err := sr.SQLHandler.Exec(query, scanData.CancelledTime, scanData.CancelledTime, scanData.CancelledBy, scanData.ID)
+	if err != nil {
+		return
+	}
+	affected, err := row.RowsAffected()
+	if err != nil || affected == 0 {
+		return
+	}
+	updScanResult = &domain.ScanResult{
+		ID:            scanData.ID,
+		Status:        sr.getStatus(5),
+		EndTime:       scanData.CancelledTime.Format(time.RFC3339),
+		CancelledTime: scanData.CancelledTime.Format(time.RFC3339),
+		CancelledBy:   scanData.CancelledBy,
+	}
+
+	return
+}
+
+// StoreAttempt is to record a run of the queued entity.
+func (sr *ScanRepository) StoreAttempt(attempt *domain.AttemptData) (err error) {
+	query := `
+		INSERT INTO scan_attempts (
+			result_id,
+			attempt,
+			start_time,
+			end_time,
+			error,
+			transient,
+			retry_time
+		)
+		VALUES (
+			?,
+			?,
+			?,
+			?,
+			?,
+			?,
+			?
+		)
+	`
+	// Attempts which are not retried have no retry time
+	attemptError := sql.NullString{String: attempt.Error, Valid: attempt.Error != ""}
+	retryTime := sql.NullTime{Time: attempt.RetryTime, Valid: !attempt.RetryTime.IsZero()}
+	_, err = sr.SQLHandler.Exec(query, attempt.ResultID, attempt.Attempt, attempt.StartTime, attempt.EndTime, attemptError, attempt.Transient, retryTime)
+
+	return
+}
+
+// StoreProgress is to record the progress of the running entity for the other instances.
+func (sr *ScanRepository) StoreProgress(resultID int64, progress *domain.ScanProgress) (err error) {
+	query := `
+		UPDATE scan_results
+		SET
+			progress = ?
+		WHERE
+			id = ?
+	`
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return
+	}
+	_, err = sr.SQLHandler.Exec(query, string(data), resultID)
+
+	return
+}
+
+// Runs of the entity in the order of the attempts
+func (sr *ScanRepository) findAttempts(resultID int64) (attempts []domain.ScanAttempt, err error) {
+	const query = `
+		SELECT
+			attempt,
+			start_time,
+			end_time,
+			error,
+			transient,
+			retry_time
+		FROM
+			scan_attempts
+		WHERE
+			result_id = ?
+		ORDER BY id
+	`
+	rows, err := sr.SQLHandler.Query(query, resultID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			attempt      int
+			startTime    sql.NullString
+			endTime      sql.NullString
+			attemptError sql.NullString
+			transient    bool
+			retryTime    sql.NullString
+		)
+		if err = rows.Scan(&attempt, &startTime, &endTime, &attemptError, &transient, &retryTime); err != nil {
+			return
+		}
+		attempts = append(attempts, domain.ScanAttempt{
+			Attempt:   attempt,
+			StartTime: startTime.String,
+			EndTime:   endTime.String,
+			Error:     attemptError.String,
+			Transient: transient,
+			RetryTime: retryTime.String,
+		})
+	}
+	err = rows.Err()
+
+	return
+}
+
+// CountActive returns the number of the queued and running entities of the repo.
+func (sr *ScanRepository) CountActive(repoID int64) (count int, err error) {
+	const query = `
+		SELECT
+			COUNT(*)
+		FROM
+			scan_results
+		WHERE
+			repo_id = ?
+		AND status IN (1, 2)
+	`
+	row, err := sr.SQLHandler.Query(query, repoID)
+	if err != nil {
+		return
+	}
+	defer row.Close()
+
+	if row.Next() {
+		err = row.Scan(&count)
+	}
+
+	return
+}
+
+// StoreUpload is to create the ad-hoc source of an uploaded archive.
+func (sr *ScanRepository) StoreUpload(upload *domain.Upload) (newUpload *domain.Upload, err error) {
+	query := `
+		INSERT INTO uploads (
+			name,
+			format,
+			size,
+			created_time
+		)
+		VALUES (
+			?,
+			?,
+			?,
+			?
+		)
+	`
+	var row Result
+	row, err = sr.SQLHandler.Exec(query, upload.Name, upload.Format, upload.Size, upload.CreatedTime)
+	if err != nil {
+		return
+	}
+	var id int64
+	id, err = row.LastInsertId()
+	if err != nil {
+		return
+	}
+	newUpload = &domain.Upload{
+		ID:          id,
+		Name:        upload.Name,
+		Format:      upload.Format,
+		Size:        upload.Size,
+		CreatedTime: upload.CreatedTime,
+	}
+
+	return
+}
+
+// FindExpiringCertificates returns the certificates of the latest successful scan of the repo
+// which expire within the given window. The diff scans are left out, they only hold the findings
+// of their added lines.
+func (sr *ScanRepository) FindExpiringCertificates(repoID int64, window time.Duration) (report *domain.CertificateReport, err error) {
+	const query = `
+		SELECT
+			id,
+			result
+		FROM
+			scan_results
+		WHERE
+			repo_id = ?
+		AND status = 3
+		AND base_ref IS NULL
+		AND head_ref IS NULL
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	row, err := sr.SQLHandler.Query(query, repoID)
+	if err != nil {
+		return
+	}
+	defer row.Close()
+
+	var (
+		id         int64
+		resultJSON string
+	)
+	if !row.Next() {
+		return
+	}
+	if err = row.Scan(&id, &resultJSON); err != nil {
+		return
+	}
+
+	var scanOutput result
+	if err = json.Unmarshal([]byte(resultJSON), &scanOutput); err != nil {
+		return
+	}
+	report = &domain.CertificateReport{
+		RepoID:       repoID,
+		ResultID:     id,
+		WindowDays:   int(window.Hours() / 24),
+		Certificates: []domain.Certificate{},
+	}
+	deadline := time.Now().UTC().Add(window)
+	for _, f := range scanOutput.Findings {
+		cert := f.Metadata.Certificate
+		if cert == nil {
+			continue
+		}
+		notAfter, parseErr := time.Parse(time.RFC3339, cert.NotAfter)
+		if parseErr != nil || notAfter.After(deadline) {
+			continue
+		}
+		var line string
+		if len(f.Location.Positions) > 0 {
+			line = f.Location.Positions[0].Begin.Line
+		}
+		report.Certificates = append(report.Certificates, domain.Certificate{
+			Path:       f.Location.Path,
+			Line:       line,
+			Subject:    cert.Subject,
+			Issuer:     cert.Issuer,
+			SANs:       cert.SANs,
+			Serial:     cert.Serial,
+			NotAfter:   cert.NotAfter,
+			SelfSigned: cert.SelfSigned,
+			Expired:    cert.Expired || time.Now().UTC().After(notAfter),
+			Severity:   f.Metadata.Severity,
+		})
 	}
 
 	return
@@ -414,6 +1122,11 @@ func (sr *ScanRepository) Update(scanData *domain.ScanData) (updScanResult *doma
 
 // Get the user readble status of scan result
 func (sr *ScanRepository) getStatus(status int8) (statusStr string) {
+	return statusText(status)
+}
+
+// Name of the status number of the scan
+func statusText(status int8) (statusStr string) {
 	switch status {
 	case 1:
 		statusStr = "Queued"
@@ -423,6 +1136,40 @@ func (sr *ScanRepository) getStatus(status int8) (statusStr string) {
 		statusStr = "Success"
 	case 4:
 		statusStr = "Failure"
+	case 5:
+		statusStr = "Cancelled"
+	}
+	return
+}
+
+// Encode the scan options for the JSON column, nil is stored as NULL
+func encodeOptions(options *domain.ScanOptions) (encoded sql.NullString, err error) {
+	if options == nil {
+		return
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// Decode the scan options of the JSON column
+func decodeOptions(encoded sql.NullString) (options *domain.ScanOptions, err error) {
+	if encoded.String == "" {
+		return
+	}
+	options = &domain.ScanOptions{}
+	err = json.Unmarshal([]byte(encoded.String), options)
+	return
+}
+
+// Decode the progress of the JSON column
+func decodeProgress(encoded sql.NullString) (progress *domain.ScanProgress, err error) {
+	if encoded.String == "" {
+		return
 	}
+	progress = &domain.ScanProgress{}
+	err = json.Unmarshal([]byte(encoded.String), progress)
 	return
 }
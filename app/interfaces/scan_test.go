@@ -2,6 +2,7 @@ package interfaces_test
 
 import (
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -182,3 +183,89 @@ func TestScanCheckViolationInWord(t *testing.T) {
 	})
 
 }
+
+// Test the store of the scans which are not queued, running or finished yet
+func TestScanStoreOnce(t *testing.T) {
+	scanData := &domain.ScanData{RepoID: 1, Status: 1, Result: `{}`, Commit: "abc", Ruleset: "r1", ScanKey: "key"}
+
+	t.Run("reused", func(t *testing.T) {
+		tx := new(mocks.Tx)
+		mockSQLHandler := new(mocks.SQLHandler)
+		mockSQLHandler.On("Begin").Return(tx, nil).Once()
+		// Lock of the repo serializes the stores of its scans
+		tx.On("Query", statement("FOR UPDATE"), []interface{}{int64(1)}).Return(mockRows(), nil).Once()
+		tx.On("Query", statement("scan_key = ?"), []interface{}{"key", true}).Return(mockRows([]interface{}{int64(3), int8(2)}), nil).Once()
+		tx.On("Rollback").Return(nil).Once()
+
+		scanResult, reused, err := (&interfaces.ScanRepository{SQLHandler: mockSQLHandler}).StoreOnce(scanData, true)
+		assert.NoError(t, err)
+		assert.True(t, reused)
+		assert.Equal(t, &domain.ScanResult{ID: 3, Status: "In Progress", Commit: "abc", Ruleset: "r1"}, scanResult)
+		tx.AssertExpectations(t)
+		tx.AssertNotCalled(t, "Commit")
+	})
+
+	t.Run("stored", func(t *testing.T) {
+		tx := new(mocks.Tx)
+		mockSQLHandler := new(mocks.SQLHandler)
+		mockSQLHandler.On("Begin").Return(tx, nil).Once()
+		tx.On("Query", statement("FOR UPDATE"), mock.Anything).Return(mockRows(), nil).Once()
+		tx.On("Query", statement("scan_key = ?"), []interface{}{"key", false}).Return(mockRows(), nil).Once()
+		inserted := new(mocks.Result)
+		inserted.On("LastInsertId").Return(int64(8), nil).Once()
+		tx.On("Exec", statement("INSERT INTO scan_results"), mock.Anything).Return(inserted, nil).Once()
+		tx.On("Commit").Return(nil).Once()
+
+		scanResult, reused, err := (&interfaces.ScanRepository{SQLHandler: mockSQLHandler}).StoreOnce(scanData, false)
+		assert.NoError(t, err)
+		assert.False(t, reused)
+		assert.Equal(t, int64(8), scanResult.ID)
+		assert.Equal(t, "Queued", scanResult.Status)
+		tx.AssertExpectations(t)
+		tx.AssertNotCalled(t, "Rollback")
+	})
+}
+
+// Test the queries which fail before their rows are read
+func TestScanRepositoryQueryError(t *testing.T) {
+	t.Run("count-active", func(t *testing.T) {
+		mockSQLHandler := new(mocks.SQLHandler)
+		mockSQLHandler.On("Query", statement("status IN (1, 2)"), []interface{}{int64(1)}).Return(nil, errors.New("connection lost")).Once()
+
+		count, err := (&interfaces.ScanRepository{SQLHandler: mockSQLHandler}).CountActive(1)
+		assert.EqualError(t, err, "connection lost")
+		assert.Zero(t, count)
+		mockSQLHandler.AssertExpectations(t)
+	})
+
+	t.Run("attempts", func(t *testing.T) {
+		mockSQLHandler := new(mocks.SQLHandler)
+		rows := mockRows([]interface{}{int64(1), "Test", "www.test.com/repo", "Queued", "", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil})
+		mockSQLHandler.On("Query", statement("FROM\n\t\t\tscan_results sr"), []interface{}{int64(1)}).Return(rows, nil).Once()
+		mockSQLHandler.On("Query", statement("FROM\n\t\t\tscan_attempts"), []interface{}{int64(1)}).Return(nil, errors.New("connection lost")).Once()
+
+		_, err := (&interfaces.ScanRepository{SQLHandler: mockSQLHandler}).FindByID(1)
+		assert.EqualError(t, err, "connection lost")
+		mockSQLHandler.AssertExpectations(t)
+		rows.AssertExpectations(t)
+	})
+}
+
+// Test the certificates of the latest full scan when a diff scan is newer
+func TestScanRepositoryFindExpiringCertificates(t *testing.T) {
+	mockSQLHandler := new(mocks.SQLHandler)
+	fullScans := mock.MatchedBy(func(query string) bool {
+		return strings.Contains(query, "base_ref IS NULL") && strings.Contains(query, "head_ref IS NULL")
+	})
+	mockSQLHandler.On("Query", fullScans, []interface{}{int64(1)}).Return(mockRows([]interface{}{int64(4), "{}"}), nil).Once()
+	// Newer diff scan of the repo holds only the findings of its added lines
+	mockSQLHandler.On("Query", mock.Anything, []interface{}{int64(1)}).Return(mockRows([]interface{}{int64(5), "{}"}), nil).Maybe()
+
+	report, err := (&interfaces.ScanRepository{SQLHandler: mockSQLHandler}).FindExpiringCertificates(1, 24*time.Hour)
+	assert.NoError(t, err)
+	if assert.NotNil(t, report) {
+		assert.Equal(t, int64(4), report.ResultID)
+		assert.Empty(t, report.Certificates)
+	}
+	mockSQLHandler.AssertExpectations(t)
+}
@@ -0,0 +1,153 @@
+package interfaces
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/scanner/app/domain"
+	"github.com/scanner/app/usecases"
+	"go.uber.org/zap"
+)
+
+// A Scheduler queues the scans of the scheduled repos when they are due. Every instance runs a
+// scheduler, and only the instance which moves a due repo to its next scan time queues the scan.
+type Scheduler struct {
+	RepoInteractor usecases.RepoInteractor
+	ScanInteractor usecases.ScanInteractor
+	ScanQueue      *ScanQueue
+	Logger         *zap.Logger
+	// Wait between the checks of the due repos
+	Interval time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewScheduler returns the scheduler of the instance which checks the due repos every 15 seconds.
+func NewScheduler(repoInteractor usecases.RepoInteractor, scanInteractor usecases.ScanInteractor, scanQueue *ScanQueue, logger *zap.Logger) *Scheduler {
+	return &Scheduler{
+		RepoInteractor: repoInteractor,
+		ScanInteractor: scanInteractor,
+		ScanQueue:      scanQueue,
+		Logger:         logger,
+		Interval:       15 * time.Second,
+		stop:           make(chan struct{}),
+	}
+}
+
+// Start checking the due repos in the background
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				s.tick(now.UTC())
+			}
+		}
+	}()
+}
+
+// Close stops the scheduler, the scans which it queued keep running on the queue.
+func (s *Scheduler) Close() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// Queue the scans of the due repos. A run is skipped when the previous scan of the repo is still
+// queued or running, the missed runs of a stopped scheduler are not repeated.
+func (s *Scheduler) tick(now time.Time) {
+	repos, err := s.RepoInteractor.Due()
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("unable to find the scheduled repos: %s", err))
+		return
+	}
+	for _, repo := range *repos {
+		repo := repo
+		// Repo with an invalid schedule is not scheduled again
+		repo.NextScanTime, err = nextScanTime(repo.Schedule, now)
+		if err != nil {
+			s.Logger.Error(fmt.Sprintf("schedule of repo %d: %s", repo.ID, err))
+		}
+		rescheduled, err := s.RepoInteractor.Reschedule(&repo)
+		if err != nil {
+			s.Logger.Error(fmt.Sprintf("unable to reschedule repo %d: %s", repo.ID, err))
+			continue
+		}
+		// Another instance fired the schedule
+		if !rescheduled {
+			continue
+		}
+
+		active, err := s.ScanInteractor.Active(repo.ID)
+		if err != nil {
+			s.Logger.Error(fmt.Sprintf("unable to find the active scans of repo %d: %s", repo.ID, err))
+			continue
+		}
+		if active > 0 {
+			s.Logger.Info(fmt.Sprintf("scheduled scan of repo %d skipped, the previous scan is still active", repo.ID))
+			continue
+		}
+		scanResult, err := s.ScanQueue.Submit(&repo, &domain.ScanData{}, "scan", scanPriorities["normal"])
+		if err != nil {
+			s.Logger.Error(fmt.Sprintf("unable to queue the scheduled scan of repo %d: %s", repo.ID, err))
+			continue
+		}
+		s.Logger.Info(fmt.Sprintf("scheduled scan %d of repo %d queued", scanResult.ID, repo.ID))
+	}
+}
+
+// Next scan time of the schedule after the given time, nil when the schedule never matches again
+func nextScanTime(schedule *domain.Schedule, after time.Time) (*time.Time, error) {
+	if schedule == nil {
+		return nil, nil
+	}
+	cron, err := parseSchedule(schedule)
+	if err != nil {
+		return nil, err
+	}
+	next := cron.next(after)
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// Encode the schedule for the JSON column, nil or none is stored as NULL
+func encodeSchedule(schedule *domain.Schedule) (encoded sql.NullString, err error) {
+	if schedule == nil || schedule.Cron == "none" {
+		return
+	}
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// Decode the schedule of the JSON column
+func decodeSchedule(encoded sql.NullString) (schedule *domain.Schedule, err error) {
+	if encoded.String == "" {
+		return
+	}
+	schedule = &domain.Schedule{}
+	err = json.Unmarshal([]byte(encoded.String), schedule)
+	return
+}
+
+// Decode the UTC time of a datetime column, nil for NULL
+func decodeTime(encoded sql.NullString) *time.Time {
+	decoded, err := time.Parse("2006-01-02 15:04:05.999999", encoded.String)
+	if err != nil {
+		return nil
+	}
+	return &decoded
+}
@@ -0,0 +1,93 @@
+package interfaces_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scanner/app/domain"
+	"github.com/scanner/app/domain/mocks"
+	"github.com/scanner/app/interfaces"
+	"github.com/scanner/app/usecases"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// Run the scheduler until the due repo is handled
+func runScheduler(t *testing.T, repo domain.Repo, mockScanRepository *mocks.ScanRepository, mockRepoRepository *mocks.RepoRepository, mockJobRepository *mocks.JobRepository, handled chan struct{}) {
+	mockRepoRepository.On("FindDue").Return(&domain.Repos{repo}, nil).Once()
+	mockRepoRepository.On("FindDue").Return(&domain.Repos{}, nil).Maybe()
+
+	scanInteractor := usecases.ScanInteractor{ScanRepository: mockScanRepository}
+	repoInteractor := usecases.RepoInteractor{RepoRepository: mockRepoRepository}
+	scanQueue := interfaces.NewScanQueue(scanInteractor, repoInteractor, usecases.JobInteractor{JobRepository: mockJobRepository}, zap.NewNop())
+	scheduler := interfaces.NewScheduler(repoInteractor, scanInteractor, scanQueue, zap.NewNop())
+	scheduler.Interval = 10 * time.Millisecond
+	scheduler.Start()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Error("due repo was not handled")
+	}
+	scheduler.Close()
+}
+
+// Test the scans of the scheduled repos
+func TestScheduler(t *testing.T) {
+	repo := domain.Repo{ID: 1, Schedule: &domain.Schedule{Cron: "*/15 * * * *"}}
+
+	t.Run("queued", func(t *testing.T) {
+		mockScanRepository := new(mocks.ScanRepository)
+		mockRepoRepository := new(mocks.RepoRepository)
+		mockJobRepository := new(mocks.JobRepository)
+		handled := make(chan struct{})
+		mockRepoRepository.On("Reschedule", mock.MatchedBy(func(repo *domain.Repo) bool {
+			return repo.ID == 1 && repo.NextScanTime != nil && repo.NextScanTime.After(time.Now()) && repo.NextScanTime.Minute()%15 == 0
+		})).Return(true, nil).Once()
+		mockScanRepository.On("CountActive", int64(1)).Return(0, nil).Once()
+		mockScanRepository.On("Store", mock.MatchedBy(func(data *domain.ScanData) bool {
+			return data.RepoID == 1 && data.Status == 1
+		})).Return(&domain.ScanResult{ID: 7}, nil).Once()
+		mockJobRepository.On("Store", mock.MatchedBy(func(job *domain.ScanJob) bool {
+			return job.ResultID == 7 && job.RepoID == 1 && job.Kind == "scan"
+		})).Return(&domain.ScanJob{ID: 3}, nil).Run(func(mock.Arguments) {
+			close(handled)
+		}).Once()
+
+		runScheduler(t, repo, mockScanRepository, mockRepoRepository, mockJobRepository, handled)
+		mockScanRepository.AssertExpectations(t)
+		mockRepoRepository.AssertExpectations(t)
+		mockJobRepository.AssertExpectations(t)
+	})
+
+	t.Run("active", func(t *testing.T) {
+		mockScanRepository := new(mocks.ScanRepository)
+		mockRepoRepository := new(mocks.RepoRepository)
+		mockJobRepository := new(mocks.JobRepository)
+		handled := make(chan struct{})
+		mockRepoRepository.On("Reschedule", mock.AnythingOfType("*domain.Repo")).Return(true, nil).Once()
+		mockScanRepository.On("CountActive", int64(1)).Return(1, nil).Run(func(mock.Arguments) {
+			close(handled)
+		}).Once()
+
+		runScheduler(t, repo, mockScanRepository, mockRepoRepository, mockJobRepository, handled)
+		mockScanRepository.AssertExpectations(t)
+		mockRepoRepository.AssertExpectations(t)
+		mockJobRepository.AssertNotCalled(t, "Store", mock.Anything)
+	})
+
+	t.Run("fired by another instance", func(t *testing.T) {
+		mockScanRepository := new(mocks.ScanRepository)
+		mockRepoRepository := new(mocks.RepoRepository)
+		mockJobRepository := new(mocks.JobRepository)
+		handled := make(chan struct{})
+		mockRepoRepository.On("Reschedule", mock.AnythingOfType("*domain.Repo")).Return(false, nil).Run(func(mock.Arguments) {
+			close(handled)
+		}).Once()
+
+		runScheduler(t, repo, mockScanRepository, mockRepoRepository, mockJobRepository, handled)
+		mockRepoRepository.AssertExpectations(t)
+		mockScanRepository.AssertNotCalled(t, "CountActive", mock.Anything)
+		assert.Empty(t, mockJobRepository.Calls)
+	})
+}
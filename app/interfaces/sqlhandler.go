@@ -4,6 +4,16 @@ package interfaces
 type SQLHandler interface {
 	Query(string, ...interface{}) (Row, error)
 	Exec(string, ...interface{}) (Result, error)
+	Begin() (Tx, error)
+	Close() error
+}
+
+// A Tx belong to the inteface layer.
+type Tx interface {
+	Query(string, ...interface{}) (Row, error)
+	Exec(string, ...interface{}) (Result, error)
+	Commit() error
+	Rollback() error
 }
 
 // A Result belong to the inteface layer.
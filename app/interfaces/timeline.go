@@ -0,0 +1,332 @@
+package interfaces
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// Exposure window of the value matched by a finding in the history of the repo
+type exposure struct {
+	IntroducedCommit string `json:"introduced_commit"`
+	IntroducedTime   string `json:"introduced_time"`
+	PresentAtHead    bool   `json:"present_at_head"`
+	// Commit which removed the value from the last file containing it
+	RemovedCommit string `json:"removed_commit,omitempty"`
+	RemovedTime   string `json:"removed_time,omitempty"`
+	// Value was deleted from HEAD, but it is still readable in the history
+	InHistoryOnly bool `json:"in_history_only,omitempty"`
+	// History of the shallow clone ends at the introduced commit, the value may be older
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// History of a matched value along the first parent history
+type valueHistory struct {
+	introduced *object.Commit
+	removed    *object.Commit
+	// Files of the current commit which contain the value
+	files map[string]bool
+	// Finding of the value in the last file version which contained it
+	last finding
+	// Value is in the first commit of a shallow history
+	incomplete bool
+}
+
+// Value of a finding position in the file version, with the finding of the position only
+type positionValue struct {
+	value   string
+	finding finding
+}
+
+// Add the exposure window of each finding position to the scan output. The history is followed
+// from the first commit to HEAD along the first parents, so the values introduced on a merged
+// branch are attributed to the merge commit. The values which were deleted before HEAD are added
+// as the findings of the last file version which contained them. Cancelling the context stops
+// the walk of the history between the commits.
+func (sr *ScanRepository) timelineFindings(ctx context.Context, gitRepo *git.Repository, directory string, output string) (string, error) {
+	var scanOutput result
+	if err := json.Unmarshal([]byte(output), &scanOutput); err != nil {
+		return "", err
+	}
+	head, err := gitRepo.Head()
+	if err != nil {
+		return "", err
+	}
+	histories, err := sr.valueHistories(ctx, gitRepo, head.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	for i := range scanOutput.Findings {
+		f := &scanOutput.Findings[i]
+		content, err := os.ReadFile(f.Location.Path)
+		if err != nil {
+			continue
+		}
+		for j := range f.Location.Positions {
+			if h, ok := histories[valueKey(*f, f.Location.Positions[j], content)]; ok {
+				f.Location.Positions[j].Exposure = h.exposure()
+			}
+		}
+	}
+
+	// Values which are only in the history, sorted so the output is stable
+	var deleted []*valueHistory
+	for _, h := range histories {
+		if len(h.files) == 0 {
+			deleted = append(deleted, h)
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool {
+		if deleted[i].removed.Committer.When.Equal(deleted[j].removed.Committer.When) {
+			return deleted[i].last.Location.Path < deleted[j].last.Location.Path
+		}
+		return deleted[i].removed.Committer.When.Before(deleted[j].removed.Committer.When)
+	})
+	for _, h := range deleted {
+		f := h.last
+		f.Location.Path = filepath.Join(directory, filepath.FromSlash(f.Location.Path))
+		f.Location.Positions[0].Exposure = h.exposure()
+		scanOutput.Findings = append(scanOutput.Findings, f)
+	}
+
+	timeline, err := json.MarshalIndent(scanOutput, "", "  ")
+	return string(timeline), err
+}
+
+// Follow the matched values from the first commit to the HEAD commit. The values of each blob
+// are checked once, as most of the blobs are shared between the commits. The history of a
+// shallow clone starts at its shallow commit, the values which are already there are incomplete.
+func (sr *ScanRepository) valueHistories(ctx context.Context, gitRepo *git.Repository, headHash plumbing.Hash) (histories map[string]*valueHistory, err error) {
+	shallow, err := gitRepo.Storer.Shallow()
+	if err != nil {
+		return
+	}
+	boundary := make(map[plumbing.Hash]bool, len(shallow))
+	for _, hash := range shallow {
+		boundary[hash] = true
+	}
+
+	var (
+		commits   []*object.Commit
+		truncated bool
+	)
+	commit, err := gitRepo.CommitObject(headHash)
+	for err == nil {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		commits = append(commits, commit)
+		if commit.NumParents() == 0 {
+			break
+		}
+		if boundary[commit.Hash] {
+			truncated = true
+			break
+		}
+		commit, err = commit.Parent(0)
+		// Missing parent ends the history like a shallow commit
+		if err == plumbing.ErrObjectNotFound {
+			truncated, err = true, nil
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	histories = make(map[string]*valueHistory)
+	blobValues := make(map[plumbing.Hash][]positionValue)
+	pathValues := make(map[string][]positionValue)
+	var parentTree *object.Tree
+	for i := len(commits) - 1; i >= 0; i-- {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		commit := commits[i]
+		var tree *object.Tree
+		if tree, err = commit.Tree(); err != nil {
+			return
+		}
+		var changes object.Changes
+		if changes, err = object.DiffTree(parentTree, tree); err != nil {
+			return
+		}
+		touched := make(map[string]*valueHistory)
+		for _, change := range changes {
+			var action merkletrie.Action
+			if action, err = change.Action(); err != nil {
+				return
+			}
+			if action != merkletrie.Insert {
+				for _, pv := range pathValues[change.From.Name] {
+					h := histories[pv.value]
+					delete(h.files, change.From.Name)
+					touched[pv.value] = h
+				}
+				delete(pathValues, change.From.Name)
+			}
+			if action == merkletrie.Delete || !scannableEntry(change.To.TreeEntry) {
+				continue
+			}
+			values, ok := blobValues[change.To.TreeEntry.Hash]
+			if !ok {
+				if values, err = sr.blobValues(gitRepo, change.To.Name, change.To.TreeEntry.Hash); err != nil {
+					return
+				}
+				blobValues[change.To.TreeEntry.Hash] = values
+			}
+			pathValues[change.To.Name] = values
+			for _, pv := range values {
+				h, ok := histories[pv.value]
+				if !ok {
+					h = &valueHistory{introduced: commit, files: make(map[string]bool), incomplete: truncated && i == len(commits)-1}
+					histories[pv.value] = h
+				}
+				h.files[change.To.Name] = true
+				h.removed = nil
+				h.last = pv.finding
+			}
+		}
+		for _, h := range touched {
+			if len(h.files) == 0 && h.removed == nil {
+				h.removed = commit
+			}
+		}
+		parentTree = tree
+	}
+	return
+}
+
+// Regular files are checked, the submodules and the symlinks are not
+func scannableEntry(entry object.TreeEntry) bool {
+	return entry.Mode != filemode.Submodule && entry.Mode != filemode.Symlink
+}
+
+// Check the blob and resolve the value of each finding position
+func (sr *ScanRepository) blobValues(gitRepo *git.Repository, path string, hash plumbing.Hash) (values []positionValue, err error) {
+	blob, err := gitRepo.BlobObject(hash)
+	if err != nil {
+		return
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	var buf bytes.Buffer
+	if _, err = buf.ReadFrom(reader); err != nil {
+		return
+	}
+	content := buf.Bytes()
+
+	fileFindings, err := sr.checkViolation(scanJob{path: path, read: func() ([]byte, error) {
+		return content, nil
+	}})
+	if err != nil {
+		return
+	}
+	for _, f := range fileFindings {
+		for _, p := range f.Location.Positions {
+			key := valueKey(f, p, content)
+			if key == "" {
+				continue
+			}
+			positionFinding := f
+			positionFinding.Location.Positions = []position{p}
+			values = append(values, positionValue{value: key, finding: positionFinding})
+		}
+	}
+	return
+}
+
+// Key of the value matched at the position, empty when the value is not known. The values are
+// hashed, so the history never holds the secrets themselves.
+func valueKey(f finding, p position, content []byte) string {
+	value := matchedValue(f, p, content)
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(f.RuleID + "\x00" + value))
+	return hex.EncodeToString(sum[:])
+}
+
+// Value matched at the position. The search patterns report the words of the line, the
+// certificates are identified by their issuer and serial, the keyword proximity rule by the
+// rest of the line, joined with the next line when the value is there, and the other rules by
+// the token at the column.
+func matchedValue(f finding, p position, content []byte) string {
+	if cert := f.Metadata.Certificate; cert != nil {
+		return cert.Issuer + "\x00" + cert.Serial
+	}
+	lineNo, err := strconv.Atoi(p.Begin.Line)
+	lines := bytes.Split(content, []byte("\n"))
+	if err != nil || lineNo < 1 || lineNo > len(lines) {
+		return ""
+	}
+	line := strings.TrimSuffix(string(lines[lineNo-1]), "\r")
+
+	var values []string
+	for _, c := range p.Begin.Cols {
+		col, err := strconv.Atoi(c)
+		if err != nil || col < 1 {
+			continue
+		}
+		switch f.RuleID {
+		case "G402":
+			words := strings.Split(line, " ")
+			if col <= len(words) {
+				values = append(values, strings.Trim(words[col-1], "\"',"))
+			}
+		case "G406":
+			value := line
+			if lineNo < len(lines) {
+				if joined, continued := continuedLine(line, string(lines[lineNo])); continued {
+					value = joined
+				}
+			}
+			if col <= len(line) {
+				values = append(values, strings.TrimSpace(value[col-1:]))
+			}
+		default:
+			if col <= len(line) {
+				token := line[col-1:]
+				if end := strings.IndexAny(token, " \t\"'`,;<>"); end >= 0 {
+					token = token[:end]
+				}
+				values = append(values, token)
+			}
+		}
+	}
+	return strings.Join(values, "\x00")
+}
+
+// Exposure window of the value
+func (h *valueHistory) exposure() *exposure {
+	e := &exposure{
+		IntroducedCommit: h.introduced.Hash.String(),
+		IntroducedTime:   h.introduced.Committer.When.UTC().Format(time.RFC3339),
+		PresentAtHead:    len(h.files) > 0,
+		Incomplete:       h.incomplete,
+	}
+	if h.removed != nil {
+		e.RemovedCommit = h.removed.Hash.String()
+		e.RemovedTime = h.removed.Committer.When.UTC().Format(time.RFC3339)
+		e.InHistoryOnly = true
+	}
+	return e
+}
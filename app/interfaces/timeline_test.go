@@ -0,0 +1,140 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/scanner/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the exposure window of the findings in the history
+func TestScanTimeline(t *testing.T) {
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git-upload-pack is required to clone local repos")
+	}
+	source := testGitRepo(t, map[string]string{"config.go": "var old = \"public_key_old\"\n"})
+	gitRepo, err := git.PlainOpen(source)
+	assert.NoError(t, err)
+	head, err := gitRepo.Head()
+	assert.NoError(t, err)
+	introduced := head.Hash()
+
+	testCommit(t, source, map[string]string{
+		"config.go":  "var old = \"public_key_old\"\nvar added = \"public_key_new\"\n",
+		"secrets.go": "var gone = \"public_key_gone\"\n",
+	})
+	head, err = gitRepo.Head()
+	assert.NoError(t, err)
+	added := head.Hash()
+
+	worktree, err := gitRepo.Worktree()
+	assert.NoError(t, err)
+	_, err = worktree.Remove("secrets.go")
+	assert.NoError(t, err)
+	deleted, err := worktree.Commit("delete secrets", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+	testCommit(t, source, map[string]string{"config.go": "var old = \"public_key_old\"\n"})
+	head, err = gitRepo.Head()
+	assert.NoError(t, err)
+	removed := head.Hash()
+
+	scanRepository := &ScanRepository{SearchPattern: []string{"public_key"}, NoOfWorkers: 2}
+	scanData, err := scanRepository.Scan(context.Background(), &domain.Repo{Url: source}, &domain.ScanOptions{Timeline: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int8(3), scanData.Status)
+
+	var scanResult result
+	assert.NoError(t, json.Unmarshal([]byte(scanData.Result), &scanResult))
+	exposures := make(map[string]*exposure)
+	for _, f := range scanResult.Findings {
+		for _, p := range f.Location.Positions {
+			exposures[filepath.Base(f.Location.Path)+":"+p.Begin.Line] = p.Exposure
+		}
+	}
+	assert.Len(t, exposures, 3)
+	commitTime := func(hash plumbing.Hash) string {
+		commit, err := gitRepo.CommitObject(hash)
+		assert.NoError(t, err)
+		return commit.Committer.When.UTC().Format(time.RFC3339)
+	}
+	assert.Equal(t, &exposure{
+		IntroducedCommit: introduced.String(),
+		IntroducedTime:   commitTime(introduced),
+		PresentAtHead:    true,
+	}, exposures["config.go:1"])
+	assert.Equal(t, &exposure{
+		IntroducedCommit: added.String(),
+		IntroducedTime:   commitTime(added),
+		RemovedCommit:    deleted.String(),
+		RemovedTime:      commitTime(deleted),
+		InHistoryOnly:    true,
+	}, exposures["secrets.go:1"])
+	assert.Equal(t, &exposure{
+		IntroducedCommit: added.String(),
+		IntroducedTime:   commitTime(added),
+		RemovedCommit:    removed.String(),
+		RemovedTime:      commitTime(removed),
+		InHistoryOnly:    true,
+	}, exposures["config.go:2"])
+
+	t.Run("shallow", func(t *testing.T) {
+		repo := &domain.Repo{Url: source, CloneStrategy: &domain.CloneStrategy{Depth: 1}}
+		scanData, err := scanRepository.Scan(context.Background(), repo, &domain.ScanOptions{Timeline: true})
+		assert.NoError(t, err)
+		assert.Equal(t, int8(3), scanData.Status)
+		var scanResult result
+		assert.NoError(t, json.Unmarshal([]byte(scanData.Result), &scanResult))
+		// History ends at the HEAD commit, which did not introduce the value
+		if assert.Len(t, scanResult.Findings, 1) {
+			assert.Equal(t, &exposure{
+				IntroducedCommit: removed.String(),
+				IntroducedTime:   commitTime(removed),
+				PresentAtHead:    true,
+				Incomplete:       true,
+			}, scanResult.Findings[0].Location.Positions[0].Exposure)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// Walk of the history and the blame stop with the scan
+		_, err := scanRepository.timelineFindings(ctx, gitRepo, source, scanData.Result)
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = scanRepository.blameFindings(ctx, gitRepo, source, scanData.Result)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// Test the values matched by the rules
+func TestMatchedValue(t *testing.T) {
+	content := []byte("package config\nvar key = \"public_key_a\", \"public_key_b\"\ndb := \"postgres://admin:pass@db/app\"\npassword = s3cr3t-Value\napi_key:\n  Zm9vYmFyYmF6cXV4MTIz\n")
+	tests := []struct {
+		name  string
+		f     finding
+		p     position
+		value string
+	}{
+		{"search pattern", finding{RuleID: "G402"}, position{Begin: begin{Line: "2", Cols: []string{"4", "5"}}}, "public_key_a\x00public_key_b"},
+		{"token", finding{RuleID: "G405"}, position{Begin: begin{Line: "3", Cols: []string{"8"}}}, "postgres://admin:pass@db/app"},
+		{"proximity", finding{RuleID: "G406"}, position{Begin: begin{Line: "4", Cols: []string{"1"}}}, "password = s3cr3t-Value"},
+		{"continued proximity", finding{RuleID: "G406"}, position{Begin: begin{Line: "5", Cols: []string{"1"}}}, "api_key: Zm9vYmFyYmF6cXV4MTIz"},
+		{"certificate", finding{RuleID: "G403", Metadata: metadata{Certificate: &certificate{Issuer: "CA", Serial: "1"}}}, position{}, "CA\x001"},
+		{"out of range", finding{RuleID: "G405"}, position{Begin: begin{Line: "9", Cols: []string{"1"}}}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.value, matchedValue(test.f, test.p, content))
+		})
+	}
+}
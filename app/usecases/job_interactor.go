@@ -0,0 +1,47 @@
+package usecases
+
+import (
+	"time"
+
+	"github.com/scanner/app/domain"
+)
+
+// A JobInteractor belong to the usecases layer.
+type JobInteractor struct {
+	JobRepository JobRepository
+}
+
+// Store is to queue new job.
+func (ji *JobInteractor) Store(job *domain.ScanJob) (newJob *domain.ScanJob, err error) {
+	return ji.JobRepository.Store(job)
+}
+
+// Claim is to lease the next queued or expired job to the owner, nil when there is none.
+func (ji *JobInteractor) Claim(owner string, lease time.Duration) (job *domain.ScanJob, err error) {
+	return ji.JobRepository.Claim(owner, lease)
+}
+
+// Renew is to extend the lease of the claimed job.
+func (ji *JobInteractor) Renew(job *domain.ScanJob, lease time.Duration) (err error) {
+	return ji.JobRepository.Renew(job, lease)
+}
+
+// Complete is to remove the finished job.
+func (ji *JobInteractor) Complete(job *domain.ScanJob) (err error) {
+	return ji.JobRepository.Complete(job)
+}
+
+// Retry is to queue the failed job again after the delay.
+func (ji *JobInteractor) Retry(job *domain.ScanJob, delay time.Duration) (err error) {
+	return ji.JobRepository.Retry(job, delay)
+}
+
+// Release is to put the claimed job back on the queue for any worker.
+func (ji *JobInteractor) Release(job *domain.ScanJob) (err error) {
+	return ji.JobRepository.Release(job)
+}
+
+// Remove is to take the jobs of the scan result off the queue.
+func (ji *JobInteractor) Remove(resultID int64) (err error) {
+	return ji.JobRepository.Remove(resultID)
+}
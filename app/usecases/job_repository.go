@@ -0,0 +1,18 @@
+package usecases
+
+import (
+	"time"
+
+	"github.com/scanner/app/domain"
+)
+
+// A JobRepository belong to the usecases layer.
+type JobRepository interface {
+	Store(*domain.ScanJob) (*domain.ScanJob, error)
+	Claim(owner string, lease time.Duration) (*domain.ScanJob, error)
+	Renew(job *domain.ScanJob, lease time.Duration) error
+	Complete(*domain.ScanJob) error
+	Retry(job *domain.ScanJob, delay time.Duration) error
+	Release(*domain.ScanJob) error
+	Remove(resultID int64) error
+}
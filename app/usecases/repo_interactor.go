@@ -34,3 +34,13 @@ func (ui *RepoInteractor) Update(repo *domain.Repo) (newRepo *domain.Repo, err e
 func (ui *RepoInteractor) Delete(repo *domain.Repo) (newRepo *domain.Repo, err error) {
 	return ui.RepoRepository.Delete(repo)
 }
+
+// Due is display the scheduled resources whose next scan time has passed.
+func (ui *RepoInteractor) Due() (repos *domain.Repos, err error) {
+	return ui.RepoRepository.FindDue()
+}
+
+// Reschedule is to move the due resource to its next scan time, false when another instance did.
+func (ui *RepoInteractor) Reschedule(repo *domain.Repo) (rescheduled bool, err error) {
+	return ui.RepoRepository.Reschedule(repo)
+}
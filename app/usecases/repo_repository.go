@@ -11,4 +11,6 @@ type RepoRepository interface {
 	Store(*domain.Repo) (*domain.Repo, error)
 	Update(*domain.Repo) (*domain.Repo, error)
 	Delete(*domain.Repo) (*domain.Repo, error)
+	FindDue() (*domain.Repos, error)
+	Reschedule(*domain.Repo) (bool, error)
 }
@@ -1,15 +1,41 @@
 package usecases
 
-import "github.com/scanner/app/domain"
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/scanner/app/domain"
+)
 
 // A ScanInteractor belong to the usecases layer.
 type ScanInteractor struct {
 	ScanRepository ScanRepository
 }
 
-// Scan the repository.
-func (si *ScanInteractor) Scan(repoUrl string) (scanData *domain.ScanData, err error) {
-	return si.ScanRepository.Scan(repoUrl)
+// Scan the repository until the context is cancelled.
+func (si *ScanInteractor) Scan(ctx context.Context, repo *domain.Repo, options *domain.ScanOptions) (scanData *domain.ScanData, err error) {
+	return si.ScanRepository.Scan(ctx, repo, options)
+}
+
+// ScanDiff scans the lines which head adds to base.
+func (si *ScanInteractor) ScanDiff(ctx context.Context, repo *domain.Repo, base, head string) (scanData *domain.ScanData, err error) {
+	return si.ScanRepository.ScanDiff(ctx, repo, base, head)
+}
+
+// ScanArchive scans the uploaded archive until the context is cancelled.
+func (si *ScanInteractor) ScanArchive(ctx context.Context, upload *domain.Upload, archive io.ReaderAt) (scanData *domain.ScanData, err error) {
+	return si.ScanRepository.ScanArchive(ctx, upload, archive)
+}
+
+// ScanContent scans the content in memory.
+func (si *ScanInteractor) ScanContent(filename string, content []byte) (scanData *domain.ScanData, err error) {
+	return si.ScanRepository.ScanContent(filename, content)
+}
+
+// StoreUpload is to create the source of an uploaded archive.
+func (si *ScanInteractor) StoreUpload(upload *domain.Upload) (newUpload *domain.Upload, err error) {
+	return si.ScanRepository.StoreUpload(upload)
 }
 
 // Index is display a listing of the resource.
@@ -27,7 +53,73 @@ func (si *ScanInteractor) Store(scanData *domain.ScanData) (newScanResult *domai
 	return si.ScanRepository.Store(scanData)
 }
 
+// StoreOnce is to create new resource unless the same scan is queued or running, or finished
+// successfully when reuseFinished is set. The existing resource is returned as reused then.
+func (si *ScanInteractor) StoreOnce(scanData *domain.ScanData, reuseFinished bool) (scanResult *domain.ScanResult, reused bool, err error) {
+	return si.ScanRepository.StoreOnce(scanData, reuseFinished)
+}
+
+// Resolve is the commit which the scan of the repository reads, empty when it has none.
+func (si *ScanInteractor) Resolve(ctx context.Context, repo *domain.Repo, base, head string) (commit string, err error) {
+	return si.ScanRepository.Resolve(ctx, repo, base, head)
+}
+
+// Ruleset is the version of the rules of the scans.
+func (si *ScanInteractor) Ruleset() string {
+	return si.ScanRepository.Ruleset()
+}
+
+// Start is to mark the queued resource as started.
+func (si *ScanInteractor) Start(scanData *domain.ScanData) (updScanResult *domain.ScanResult, err error) {
+	return si.ScanRepository.Start(scanData)
+}
+
+// Requeue is to mark the running resource as queued again.
+func (si *ScanInteractor) Requeue(resultID int64) (err error) {
+	return si.ScanRepository.Requeue(resultID)
+}
+
 // Update is to update existing resource.
 func (si *ScanInteractor) Update(scanData *domain.ScanData) (newScanResult *domain.ScanResult, err error) {
 	return si.ScanRepository.Update(scanData)
 }
+
+// Cancel is to mark the queued or running resource as cancelled.
+func (si *ScanInteractor) Cancel(scanData *domain.ScanData) (updScanResult *domain.ScanResult, err error) {
+	return si.ScanRepository.Cancel(scanData)
+}
+
+// StoreAttempt is to record a run of the queued scan.
+func (si *ScanInteractor) StoreAttempt(attempt *domain.AttemptData) (err error) {
+	return si.ScanRepository.StoreAttempt(attempt)
+}
+
+// StoreProgress is to record the progress of the running scan.
+func (si *ScanInteractor) StoreProgress(resultID int64, progress *domain.ScanProgress) (err error) {
+	return si.ScanRepository.StoreProgress(resultID, progress)
+}
+
+// Active is the number of the queued and running scans of the repository.
+func (si *ScanInteractor) Active(repoID int64) (count int, err error) {
+	return si.ScanRepository.CountActive(repoID)
+}
+
+// Heartbeat is to renew the heartbeat of the instance which runs the scans.
+func (si *ScanInteractor) Heartbeat() (err error) {
+	return si.ScanRepository.Heartbeat()
+}
+
+// Recover is to queue again or fail the running scans of the dead instances.
+func (si *ScanInteractor) Recover(ownerTimeout time.Duration) (requeued []int64, failed []int64, err error) {
+	return si.ScanRepository.Recover(ownerTimeout)
+}
+
+// SweepClones is to remove the clone directories which no scan uses.
+func (si *ScanInteractor) SweepClones(ownerTimeout time.Duration) (removed []string, err error) {
+	return si.ScanRepository.SweepClones(ownerTimeout)
+}
+
+// Certificates is display the certificates of the repository which expire within the window.
+func (si *ScanInteractor) Certificates(repoID int64, window time.Duration) (report *domain.CertificateReport, err error) {
+	return si.ScanRepository.FindExpiringCertificates(repoID, window)
+}
@@ -1,12 +1,35 @@
 package usecases
 
-import "github.com/scanner/app/domain"
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/scanner/app/domain"
+)
 
 // A ScanRepository belong to the usecases layer.
 type ScanRepository interface {
-	Scan(repoUrl string) (*domain.ScanData, error)
+	Scan(ctx context.Context, repo *domain.Repo, options *domain.ScanOptions) (*domain.ScanData, error)
+	ScanDiff(ctx context.Context, repo *domain.Repo, base, head string) (*domain.ScanData, error)
+	ScanArchive(ctx context.Context, upload *domain.Upload, archive io.ReaderAt) (*domain.ScanData, error)
+	ScanContent(filename string, content []byte) (*domain.ScanData, error)
+	StoreUpload(*domain.Upload) (*domain.Upload, error)
 	FindAll() (*domain.ScanResults, error)
 	FindByID(int64) (*domain.ScanResult, error)
 	Store(*domain.ScanData) (*domain.ScanResult, error)
+	StoreOnce(scanData *domain.ScanData, reuseFinished bool) (*domain.ScanResult, bool, error)
+	Resolve(ctx context.Context, repo *domain.Repo, base, head string) (string, error)
+	Ruleset() string
+	Start(*domain.ScanData) (*domain.ScanResult, error)
+	Requeue(resultID int64) error
 	Update(*domain.ScanData) (*domain.ScanResult, error)
+	Cancel(*domain.ScanData) (*domain.ScanResult, error)
+	StoreAttempt(*domain.AttemptData) error
+	StoreProgress(resultID int64, progress *domain.ScanProgress) error
+	CountActive(repoID int64) (int, error)
+	Heartbeat() error
+	Recover(ownerTimeout time.Duration) (requeued []int64, failed []int64, err error)
+	SweepClones(ownerTimeout time.Duration) ([]string, error)
+	FindExpiringCertificates(repoID int64, window time.Duration) (*domain.CertificateReport, error)
 }